@@ -3,30 +3,36 @@ package loki
 import (
 	"fmt"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	fake "github.com/brianvoe/gofakeit/v6"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/loki/pkg/push"
 	json "github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
 	"github.com/prometheus/common/model"
-	"go.k6.io/k6/js/common"
 )
 
-var LabelValuesFormat = []string{"apache_common", "apache_combined", "apache_error", "rfc3164", "rfc5424", "json", "logfmt"}
-
-type FakeFunc func() string
-
-type LabelPool map[model.LabelName][]string
+var LabelValuesFormat = []string{"apache_common", "apache_combined", "apache_error", "rfc3164", "rfc5424", "json", "logfmt", "json_nested", "logfmt_wide", "pattern", "common_log", "nginx", "klog", "kubernetes_event", "envoy", "cef", "docker_json", "stacktrace", "stacktrace_java", "stacktrace_python", "stacktrace_go"}
 
 type Batch struct {
 	Streams   map[string]*push.Stream
 	Bytes     int
 	CreatedAt time.Time
+	// labels are the label sets of the streams, by the same key as Streams
+	labels map[string]model.LabelSet
+	// needles are the lines with needles in the batch, by token
+	needles map[string]*NeedleStats
+	// fuzzed are the number of fuzzed lines in the batch, by case
+	fuzzed map[string]int
+	// pushed is whether the batch was pushed successfully before
+	pushed bool
+	// drawn is set for batches that are drawn from a pool, which are
+	// encoded from the pre-encoded entries of the pool
+	drawn *drawnBatch
 }
 
 type Entry struct {
@@ -39,7 +45,7 @@ type Entry struct {
 //easyjson:json
 type JSONStream struct {
 	Stream map[string]string `json:"stream"`
-	Values [][]string        `json:"values"`
+	Values []JSONEntry       `json:"values"`
 }
 
 //easyjson:json
@@ -47,6 +53,66 @@ type JSONPushRequest struct {
 	Streams []JSONStream `json:"streams"`
 }
 
+// JSONEntry is a log entry of the JSON payload of push requests. It is encoded
+// as `[timestamp, line]`, or as `[timestamp, line, structuredMetadata]` if it
+// has structured metadata.
+type JSONEntry struct {
+	Timestamp          string
+	Line               string
+	StructuredMetadata map[string]string
+}
+
+// MarshalEasyJSON implements easyjson.Marshaler
+func (e JSONEntry) MarshalEasyJSON(w *jwriter.Writer) {
+	w.RawByte('[')
+	w.String(e.Timestamp)
+	e.marshalTail(w)
+}
+
+// marshalTail writes the line and the structured metadata of the entry, which
+// follow the timestamp, and the closing bracket
+func (e JSONEntry) marshalTail(w *jwriter.Writer) {
+	w.RawByte(',')
+	w.String(e.Line)
+	if len(e.StructuredMetadata) > 0 {
+		w.RawString(`,{`)
+		first := true
+		for k, v := range e.StructuredMetadata {
+			if !first {
+				w.RawByte(',')
+			}
+			first = false
+			w.String(k)
+			w.RawByte(':')
+			w.String(v)
+		}
+		w.RawByte('}')
+	}
+	w.RawByte(']')
+}
+
+// UnmarshalEasyJSON implements easyjson.Unmarshaler
+func (e *JSONEntry) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	l.Delim('[')
+	e.Timestamp = l.String()
+	l.WantComma()
+	e.Line = l.String()
+	l.WantComma()
+	if !l.IsDelim(']') {
+		l.Delim('{')
+		e.StructuredMetadata = make(map[string]string)
+		for !l.IsDelim('}') {
+			k := l.String()
+			l.WantColon()
+			e.StructuredMetadata[k] = l.String()
+			l.WantComma()
+		}
+		l.Delim('}')
+		l.WantComma()
+	}
+	l.Delim(']')
+}
+
 func isValidLogFormat(format string) bool {
 	for _, f := range LabelValuesFormat {
 		if f == format {
@@ -56,21 +122,58 @@ func isValidLogFormat(format string) bool {
 	return false
 }
 
+// stream returns the stream of the batch with the given labels, and adds it
+// to the batch if it does not exist yet
+func (b *Batch) stream(labels model.LabelSet) *push.Stream {
+	key := labels.String()
+	if stream, ok := b.Streams[key]; ok {
+		return stream
+	}
+	stream := &push.Stream{Labels: key}
+	b.Streams[key] = stream
+	if b.labels == nil {
+		b.labels = make(map[string]model.LabelSet)
+	}
+	b.labels[key] = labels
+	return stream
+}
+
+// lines returns the total number of entries of all streams in the batch
+func (b *Batch) lines() int {
+	lines := 0
+	for _, stream := range b.Streams {
+		lines += len(stream.Entries)
+	}
+	return lines
+}
+
 // encodeSnappy encodes the batch as snappy-compressed push request, and
-// returns the encoded bytes and the number of encoded entries
-func (b *Batch) encodeSnappy() ([]byte, int, error) {
-	req, entriesCount := b.createPushRequest()
-	buf, err := proto.Marshal(req)
-	if err != nil {
-		return nil, 0, err
+// returns the encoded bytes, the number of encoded entries, and the
+// compression ratio of snappy
+func (b *Batch) encodeSnappy() ([]byte, int, float64, error) {
+	var raw []byte
+	var entriesCount int
+	if b.drawn != nil {
+		raw, entriesCount = b.drawn.encodeProtobuf()
+	} else {
+		req, n := b.createPushRequest()
+		buf, err := proto.Marshal(req)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		raw, entriesCount = buf, n
 	}
-	buf = snappy.Encode(nil, buf)
-	return buf, entriesCount, nil
+	buf := snappy.Encode(nil, raw)
+	return buf, entriesCount, float64(len(raw)) / float64(len(buf)), nil
 }
 
 // encodeJSON encodes the batch as JSON push request, and returns the encoded
 // bytes and the number of encoded entries
 func (b *Batch) encodeJSON() ([]byte, int, error) {
+	if b.drawn != nil {
+		buf, entriesCount := b.drawn.encodeJSON()
+		return buf, entriesCount, nil
+	}
 	req, entriesCount := b.createJSONPushRequest()
 	buf, err := json.Marshal(req)
 	if err != nil {
@@ -87,9 +190,9 @@ func (b *Batch) createJSONPushRequest() (*JSONPushRequest, int) {
 	}
 
 	entriesCount := 0
-	for _, stream := range b.Streams {
+	for key, stream := range b.Streams {
 		req.Streams = append(req.Streams, JSONStream{
-			Stream: labelStringToMap(stream.Labels),
+			Stream: labelSetToMap(b.labels[key]),
 			Values: entriesToValues(stream.Entries),
 		})
 		entriesCount += len(stream.Entries)
@@ -97,33 +200,44 @@ func (b *Batch) createJSONPushRequest() (*JSONPushRequest, int) {
 	return &req, entriesCount
 }
 
-// labelStringToMap converts a label string used by the `Batch` struct in
-// format `{label_a="value_a",label_b="value_b"}` to a map that can be used in the
-// JSON payload of push requests.
-func labelStringToMap(labels string) map[string]string {
-	kvList := strings.Trim(labels, "{}")
-	kv := strings.Split(kvList, ",")
-	labelMap := make(map[string]string, len(kv))
-	for _, item := range kv {
-		parts := strings.Split(item, "=")
-		labelMap[parts[0]] = parts[1][1 : len(parts[1])-1]
+// labelSetToMap converts a label set to a map that can be used in the JSON
+// payload of push requests.
+func labelSetToMap(labels model.LabelSet) map[string]string {
+	labelMap := make(map[string]string, len(labels))
+	for name, value := range labels {
+		labelMap[string(name)] = string(value)
 	}
 	return labelMap
 }
 
-// entriesToValues converts a slice of `Entry` to a slice of string tuples that
+// entriesToValues converts a slice of `Entry` to a slice of JSON entries that
 // can be used in the JSON payload of push requests.
-func entriesToValues(entries []push.Entry) [][]string {
-	lines := make([][]string, 0, len(entries))
+func entriesToValues(entries []push.Entry) []JSONEntry {
+	lines := make([]JSONEntry, 0, len(entries))
 	for _, entry := range entries {
-		lines = append(lines, []string{
-			strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
-			entry.Line,
+		lines = append(lines, JSONEntry{
+			Timestamp:          strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+			Line:               entry.Line,
+			StructuredMetadata: structuredMetadataToMap(entry.StructuredMetadata),
 		})
 	}
 	return lines
 }
 
+// structuredMetadataToMap converts the structured metadata of an entry to a
+// map that can be used in the JSON payload of push requests, or nil if the
+// entry has no structured metadata.
+func structuredMetadataToMap(metadata push.LabelsAdapter) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(metadata))
+	for _, l := range metadata {
+		result[l.Name] = l.Value
+	}
+	return result
+}
+
 // createPushRequest creates a push request and returns it, together with
 // number of entries
 func (b *Batch) createPushRequest() (*push.PushRequest, int) {
@@ -139,113 +253,134 @@ func (b *Batch) createPushRequest() (*push.PushRequest, int) {
 	return &req, entriesCount
 }
 
-// getRandomLabelSet creates a label set from the possible Client labels
-func (c *Client) getRandomLabelSet() model.LabelSet {
-	// TODO: improve it so there is no possibility to return duplicate label sets for the same batch?
-	ls := make(model.LabelSet, len(c.labels))
-	for _, label := range c.labels {
-		ls[label.name] = model.LabelValue(label.values[c.rand.Intn(len(label.values))])
+// newBatch creates a batch with randomly generated log streams. Each stream of
+// the batch has a distinct label set. An error is returned if there are fewer
+// possible label sets than the requested number of streams.
+func (c *Client) newBatch(numStreams, minBatchSize, maxBatchSize int) (*Batch, error) {
+	if numStreams < 0 || (numStreams == 0 && len(c.rates) == 0) {
+		return nil, fmt.Errorf("a batch needs at least one stream")
 	}
-	return ls
-}
-
-// newBatch creates a batch with randomly generated log streams
-func (c *Client) newBatch(numStreams, minBatchSize, maxBatchSize int) *Batch {
 	batch := &Batch{
 		Streams:   make(map[string]*push.Stream, numStreams),
 		CreatedAt: time.Now(),
 	}
-	state := c.vu.State()
+	c.churnLabels(batch.CreatedAt)
 
-	hostname, err := os.Hostname()
+	labelSets, err := c.getDistinctLabelSets(numStreams)
 	if err != nil {
-		hostname = "localhost"
+		return nil, err
 	}
 
+	instance := c.instance()
+
 	maxSizePerStream := minBatchSize
 	if minBatchSize != maxBatchSize {
 		maxSizePerStream += c.rand.Intn(maxBatchSize - minBatchSize)
 	}
-	maxSizePerStream /= numStreams
+	if numStreams > 0 {
+		maxSizePerStream /= numStreams
+	}
 
-	for i := 0; i < numStreams; i++ {
-		labels := c.getRandomLabelSet()
+	for i, labels := range labelSets {
 		if _, ok := labels[model.InstanceLabel]; !ok {
-			labels[model.InstanceLabel] = model.LabelValue(fmt.Sprintf("vu%d.%s", state.VUID, hostname))
+			labels[model.InstanceLabel] = instance
 		}
-		stream := &push.Stream{Labels: labels.String()}
-		batch.Streams[stream.Labels] = stream
+		stream := batch.stream(labels)
 
 		var now time.Time
-		logFmt := string(labels[model.LabelName("format")])
-		if !isValidLogFormat(logFmt) {
-			common.Throw(c.vu.Runtime(), fmt.Errorf("%s is not a valid log format", logFmt))
+		logFmt, err := streamFormat(labels)
+		if err != nil {
+			return nil, err
 		}
-		var line string
+		var entry push.Entry
 
 		// We have batch.Bytes so far, and each stream is allotted around
 		// maxSizePerStream, so our final byte this stream should be:
 		streamMaxByte := maxSizePerStream * (i + 1)
-		for ; batch.Bytes < streamMaxByte; batch.Bytes += len(line) {
+		for ; batch.Bytes < streamMaxByte; batch.Bytes += len(entry.Line) {
 			now = time.Now()
-			line = c.flog.LogLine(logFmt, now)
-			stream.Entries = append(stream.Entries, push.Entry{
-				Timestamp: now,
-				Line:      line,
-			})
+			entry = c.logEntry(labels, logFmt, now)
+			stream.Entries = append(stream.Entries, entry)
 		}
 	}
 
-	return batch
-}
-
-// generateValues returns `n` label values generated with the `ff` gofakeit function
-func generateValues(ff FakeFunc, n int) []string {
-	res := make([]string, n)
-	for i := 0; i < n; i++ {
-		res[i] = ff()
+	if err := c.addRatedStreams(batch, instance); err != nil {
+		return nil, err
 	}
-	return res
+	c.findNeedles(batch)
+	c.collectFuzzedLines(batch)
+
+	return batch, nil
 }
 
-// newLabelPool creates a "pool" of values for each label name
-func newLabelPool(faker *fake.Faker, cardinalities map[string]int) LabelPool {
-	// TODO: fix so that other cardinalities values work
-	lb := LabelPool{
-		"format": []string{"apache_common", "apache_combined", "apache_error", "rfc3164", "rfc5424", "json", "logfmt"}, // needs to match the available flog formats
-		"os":     []string{"darwin", "linux", "windows"},
+// instance returns the value of the instance label of the streams of the
+// client. Clients without VU generate the batches of a pool.
+func (c *Client) instance() model.LabelValue {
+	if c.vu == nil {
+		return poolInstance
 	}
-	if n, ok := cardinalities["namespace"]; ok {
-		lb["namespace"] = generateValues(faker.BS, n)
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
 	}
-	if n, ok := cardinalities["app"]; ok {
-		lb["app"] = generateValues(faker.AppName, n)
+	return model.LabelValue(fmt.Sprintf("vu%d.%s", c.vu.State().VUID, hostname))
+}
+
+// streamFormat returns the log format of a stream, which is defined by the
+// `format` label
+func streamFormat(labels model.LabelSet) (string, error) {
+	logFmt := string(labels[model.LabelName("format")])
+	if !isValidLogFormat(logFmt) {
+		return "", fmt.Errorf("%s is not a valid log format", logFmt)
 	}
-	if n, ok := cardinalities["pod"]; ok {
-		lb["pod"] = generateValues(faker.BS, n)
+	return logFmt, nil
+}
+
+// logLine generates a log line in the given format for the stream with the
+// given labels
+func (c *Client) logLine(labels model.LabelSet, format string, t time.Time) string {
+	var line string
+	if format == "pattern" && c.patterns != nil {
+		line = c.flog.NewPatternLog(c.patterns.get(labels), t)
+	} else {
+		line = c.flog.LogLine(format, t)
 	}
-	if n, ok := cardinalities["language"]; ok {
-		lb["language"] = generateValues(faker.LanguageAbbreviation, n)
+	if c.stacktraceRatio > 0 && !strings.HasPrefix(format, "stacktrace") && c.rand.Float64() < c.stacktraceRatio {
+		line = c.flog.WithStackTrace(format, line)
 	}
-	if n, ok := cardinalities["word"]; ok {
-		lb["word"] = generateValues(faker.Noun, n)
+	if c.lineLength != nil {
+		line = c.flog.Resize(line, c.lineLength.sample())
 	}
-	return lb
+	return line
 }
 
-func transformLabelPool(pool LabelPool) []labelValues {
-	keys := make([]string, 0, len(pool))
-	for k := range pool {
-		keys = append(keys, string(k))
+// logEntry generates a log entry with a line in the given format. A recent
+// line is repeated instead, if repetition is configured.
+func (c *Client) logEntry(labels model.LabelSet, format string, t time.Time) push.Entry {
+	var line string
+	repeated := false
+	key := format
+	if format == "pattern" {
+		// lines of the pattern format are only repeated within the
+		// stream, since each stream has its own templates
+		key = format + labels.String()
 	}
-	sort.Strings(keys)
-	result := make([]labelValues, len(pool))
-	for i, k := range keys {
-		ln := model.LabelName(k)
-		result[i] = labelValues{
-			name:   ln,
-			values: pool[ln],
+	if c.repetition != nil {
+		line, repeated = c.repetition.repeat(c.rand, key)
+	}
+	if !repeated {
+		line = c.logLine(labels, format, t)
+		if c.repetition != nil {
+			c.repetition.add(key, line)
 		}
 	}
-	return result
+	entry := push.Entry{Timestamp: t, Line: line}
+	c.fuzz(&entry)
+	// trace IDs and needles are added after resizing and fuzzing, so they
+	// are never truncated or broken up
+	if c.traces != nil {
+		c.traces.add(c.rand, &entry)
+	}
+	c.addNeedles(&entry)
+	return entry
 }
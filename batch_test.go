@@ -2,9 +2,13 @@ package loki
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+	json "github.com/mailru/easyjson"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/metrics"
@@ -35,19 +39,84 @@ func BenchmarkNewBatch(b *testing.B) {
 		"pod":       100,
 	}
 	streams, minBatchSize, maxBatchSize := 5, 500, 1000
-	labels := newLabelPool(faker, cardinalities)
+	labels, err := newLabelPool(faker, cardinalities, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
 
 	c := Client{
 		vu:     vu,
+		rand:   faker.Rand,
+		faker:  faker,
+		flog:   flog.New(faker.Rand, faker),
 		labels: transformLabelPool(labels),
 	}
 	b.ResetTimer()
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_ = c.newBatch(streams, minBatchSize, maxBatchSize)
+		if _, err := c.newBatch(streams, minBatchSize, maxBatchSize); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
+// BenchmarkPool compares generating and encoding a batch per push with
+// drawing and encoding a batch from a pool
+func BenchmarkPool(b *testing.B) {
+	vu := &modulestest.VU{
+		CtxField:   context.Background(),
+		StateField: &lib.State{VUID: 15},
+	}
+	config := &Config{
+		Cardinalities: map[string]int{"app": 5, "namespace": 10, "pod": 100},
+		Patterns:      Patterns{Templates: flog.DefaultPatternTemplates, Slots: flog.DefaultPatternSlots},
+		RandSeed:      12345,
+		Pool:          &Pool{Name: DefaultPoolName, Size: 10, Streams: 5, MinSize: 100 * 1024, MaxSize: 200 * 1024},
+	}
+	c, err := newClient(vu, lokiMetrics{}, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var pools batchPools
+	c.pool, err = pools.get(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("generate protobuf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			batch, err := c.newBatch(5, 100*1024, 200*1024)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _, _, _ = batch.encodeSnappy()
+		}
+	})
+
+	b.Run("pool protobuf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			batch, err := c.drawBatch(time.Now(), "vu15.localhost")
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _, _, _ = batch.encodeSnappy()
+		}
+	})
+
+	b.Run("pool json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			batch, err := c.drawBatch(time.Now(), "vu15.localhost")
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _, _ = batch.encodeJSON()
+		}
+	})
+}
+
 func BenchmarkEncode(b *testing.B) {
 	samples := make(chan metrics.SampleContainer)
 	state := &lib.State{
@@ -74,19 +143,28 @@ func BenchmarkEncode(b *testing.B) {
 		"pod":       100,
 	}
 	streams, minBatchSize, maxBatchSize := 5, 500, 1000
-	labels := newLabelPool(faker, cardinalities)
+	labels, err := newLabelPool(faker, cardinalities, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
 
 	c := Client{
 		vu:     vu,
+		rand:   faker.Rand,
+		faker:  faker,
+		flog:   flog.New(faker.Rand, faker),
 		labels: transformLabelPool(labels),
 	}
-	batch := c.newBatch(streams, minBatchSize, maxBatchSize)
+	batch, err := c.newBatch(streams, minBatchSize, maxBatchSize)
+	if err != nil {
+		b.Fatal(err)
+	}
 
 	b.Run("encode protobuf", func(b *testing.B) {
 		b.ReportAllocs()
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
-			_, _, _ = batch.encodeSnappy()
+			_, _, _, _ = batch.encodeSnappy()
 		}
 	})
 
@@ -98,3 +176,88 @@ func BenchmarkEncode(b *testing.B) {
 		}
 	})
 }
+
+func TestNewBatchDistinctStreams(t *testing.T) {
+	vu := &modulestest.VU{
+		CtxField:   context.Background(),
+		StateField: &lib.State{VUID: 15},
+	}
+	faker := gofakeit.New(12345)
+	c := Client{
+		vu:    vu,
+		rand:  faker.Rand,
+		faker: faker,
+		flog:  flog.New(faker.Rand, faker),
+		labels: transformLabelPool(LabelPool{
+			"format": {"json", "logfmt"},
+			"app":    {"api", "frontend", "batch"},
+		}),
+	}
+
+	for i := 0; i < 10; i++ {
+		batch, err := c.newBatch(6, 6000, 6000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch.Streams) != 6 {
+			t.Fatalf("expected 6 distinct streams, got %d", len(batch.Streams))
+		}
+		for labels, stream := range batch.Streams {
+			if len(stream.Entries) == 0 {
+				t.Fatalf("expected entries for stream %s", labels)
+			}
+		}
+	}
+
+	if _, err := c.newBatch(7, 6000, 6000); err == nil {
+		t.Fatal("expected error when requesting more streams than possible label sets")
+	}
+}
+
+func TestNewBatchLabelValuesWithCommas(t *testing.T) {
+	vu := &modulestest.VU{
+		CtxField:   context.Background(),
+		StateField: &lib.State{VUID: 15},
+	}
+	faker := gofakeit.New(12345)
+	labels, err := newLabelPool(faker, map[string]int{"company": 20, "team": 3}, map[string]string{
+		"company": "Company",
+		"team":    `{{fake "Company"}}, "team"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{
+		vu:     vu,
+		rand:   faker.Rand,
+		faker:  faker,
+		flog:   flog.New(faker.Rand, faker),
+		labels: transformLabelPool(labels),
+	}
+
+	batch, err := c.newBatch(5, 5000, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _, err := batch.encodeJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req JSONPushRequest
+	if err := json.Unmarshal(buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	for _, stream := range req.Streams {
+		if !strings.HasSuffix(stream.Stream["team"], `, "team"`) {
+			t.Fatalf("expected team label with comma and quotes, got %v", stream.Stream)
+		}
+	}
+
+	stats := newWriteStats()
+	stats.add(batch)
+	for value := range stats.labelBytes["team"] {
+		if !strings.HasSuffix(value, `, "team"`) {
+			t.Fatalf("expected team label with comma and quotes, got %q", value)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package loki
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+	"github.com/prometheus/common/model"
+)
+
+// Churn defines how the values of a label are rotated over time, for example
+// to simulate pods that are replaced on every deployment.
+type Churn struct {
+	// Ratio is the fraction of values that are replaced on each rotation
+	Ratio float64
+	// Interval is the duration after which values are rotated
+	Interval time.Duration
+	// Iterations is the number of generated batches after which values are
+	// rotated
+	Iterations int
+}
+
+type labelChurn struct {
+	Churn
+	generate FakeFunc
+	last     time.Time
+	batches  int
+}
+
+// due returns whether the values of the label need to be rotated
+func (c *labelChurn) due(now time.Time) bool {
+	c.batches++
+	if c.Interval > 0 && now.Sub(c.last) >= c.Interval {
+		return true
+	}
+	return c.Iterations > 0 && c.batches >= c.Iterations
+}
+
+// rotate replaces a random fraction of the label values with newly generated
+// values.
+func (l *labelValues) rotate(rand *rand.Rand, now time.Time) {
+	n := int(math.Ceil(l.churn.Ratio * float64(len(l.values))))
+	if n > len(l.values) {
+		n = len(l.values)
+	}
+
+	existing := make(map[string]struct{}, len(l.values))
+	for _, v := range l.values {
+		existing[v] = struct{}{}
+	}
+
+	// copy the values, since they are shared with the label pool of the config
+	values := append([]string(nil), l.values...)
+	for _, i := range rand.Perm(len(values))[:n] {
+		v := nextDistinctValue(l.churn.generate, existing)
+		existing[v] = struct{}{}
+		values[i] = v
+	}
+	l.values = values
+	l.churn.last = now
+	l.churn.batches = 0
+}
+
+// hasValue returns a function that returns whether the value of the label in
+// a label set is one of the current values. Label sets without the label are
+// always accepted.
+func (l *labelValues) hasValue() func(model.LabelSet) bool {
+	current := make(map[string]struct{}, len(l.values))
+	for _, v := range l.values {
+		current[v] = struct{}{}
+	}
+	return func(ls model.LabelSet) bool {
+		v, ok := ls[l.name]
+		return !ok || contains(current, string(v))
+	}
+}
+
+// rotate replaces a random fraction of the values of the last level of the
+// hierarchy with newly generated values. The fraction is replaced for each
+// value of the parent level, so that each parent keeps the same number of
+// values.
+func (h *labelHierarchy) rotate(rand *rand.Rand, now time.Time) {
+	last := len(h.names) - 1
+	size := h.sizes[last]
+	n := int(math.Ceil(h.churn.Ratio * float64(size)))
+	if n > size {
+		n = size
+	}
+
+	// copy the leaves, since the rotated leaves are replaced
+	leaves := append([][]model.LabelValue(nil), h.leaves...)
+	for start := 0; start < len(leaves); start += size {
+		siblings := leaves[start : start+size]
+		existing := make(map[string]struct{}, size)
+		for _, leaf := range siblings {
+			existing[string(leaf[last])] = struct{}{}
+		}
+		generate := h.generate.withData(h.data(siblings[0][:last]))
+		for _, i := range rand.Perm(size)[:n] {
+			v := nextDistinctValue(generate, existing)
+			existing[v] = struct{}{}
+			leaf := append([]model.LabelValue(nil), siblings[i]...)
+			leaf[last] = model.LabelValue(v)
+			siblings[i] = leaf
+		}
+	}
+	h.leaves = leaves
+	h.churn.last = now
+	h.churn.batches = 0
+}
+
+// nextDistinctValue returns a value generated by `ff` that is not in
+// `existing`. If the generator does not produce a distinct value, the value is
+// made unique by adding a numeric suffix.
+func nextDistinctValue(ff FakeFunc, existing map[string]struct{}) string {
+	for attempts := 0; attempts < len(existing)+maxGenerateAttempts; attempts++ {
+		if v := ff(); !contains(existing, v) {
+			return v
+		}
+	}
+	base := ff()
+	for i := 0; ; i++ {
+		if v := fmt.Sprintf("%s-%d", base, i); !contains(existing, v) {
+			return v
+		}
+	}
+}
+
+func contains(set map[string]struct{}, v string) bool {
+	_, ok := set[v]
+	return ok
+}
+
+// churnLabels rotates the values of all labels with churn that are due
+func (c *Client) churnLabels(now time.Time) {
+	for i := range c.labels {
+		if l := &c.labels[i]; l.churn != nil && l.churn.due(now) {
+			l.rotate(c.rand, now)
+			if c.stats != nil {
+				c.stats.expire(l.hasValue())
+			}
+		}
+	}
+	if h := c.hierarchy; h != nil && h.churn != nil && h.churn.due(now) {
+		h.rotate(c.rand, now)
+		if c.stats != nil {
+			c.stats.expire(h.hasLeaf())
+		}
+	}
+}
+
+// applyChurn sets the churn configuration of the labels. New values of labels
+// without a generator are generated with the template `{label}-{{hex 8}}`.
+// Only the values of the last level of the hierarchy can churn, since the
+// values of the other levels are the parents of other values.
+func applyChurn(faker *fake.Faker, labels []labelValues, h *labelHierarchy, churn map[string]Churn, generators map[string]string, now time.Time) error {
+	for name, ch := range churn {
+		if name == "format" {
+			return fmt.Errorf("the values of label %q cannot churn", name)
+		}
+		if ch.Ratio <= 0 || ch.Ratio > 1 {
+			return fmt.Errorf("churn ratio of label %q needs to be greater than 0 and less or equal to 1", name)
+		}
+		if ch.Interval <= 0 && ch.Iterations <= 0 {
+			return fmt.Errorf("churn of label %q requires either an interval or iterations", name)
+		}
+		if h != nil && h.has(model.LabelName(name)) {
+			if last := h.names[len(h.names)-1]; string(last) != name {
+				return fmt.Errorf("churn of hierarchical label %q is not supported, only the last level %q can churn", name, last)
+			}
+			ff, err := labelGenerator(faker, name, generators, name+"-{{hex 8}}")
+			if err != nil {
+				return err
+			}
+			h.churn = &labelChurn{Churn: ch, last: now}
+			h.generate = ff
+			continue
+		}
+		found := false
+		for i := range labels {
+			if string(labels[i].name) != name {
+				continue
+			}
+			ff, err := labelGenerator(faker, name, generators, name+"-{{hex 8}}")
+			if err != nil {
+				return err
+			}
+			labels[i].churn = &labelChurn{Churn: ch, generate: ff.withData(nil), last: now}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("churn defined for unknown label %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package loki
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+)
+
+func TestLabelChurn(t *testing.T) {
+	faker := gofakeit.New(12345)
+	pool, err := newLabelPool(faker, map[string]int{"pod": 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := transformLabelPool(pool)
+	start := time.Now()
+	if err := applyChurn(faker, labels, nil, map[string]Churn{"pod": {Ratio: 0.2, Iterations: 3}}, map[string]string{"pod": "pod-%d"}, start); err != nil {
+		t.Fatal(err)
+	}
+	c := Client{rand: rand.New(rand.NewSource(1)), labels: labels}
+	pod := &c.labels[len(c.labels)-1]
+	initial := append([]string(nil), pod.values...)
+
+	c.churnLabels(start)
+	c.churnLabels(start)
+	if changed := countChanged(initial, pod.values); changed != 0 {
+		t.Fatalf("expected no values to be rotated before 3 iterations, got %d", changed)
+	}
+
+	c.churnLabels(start)
+	if changed := countChanged(initial, pod.values); changed != 2 {
+		t.Fatalf("expected 2 values to be rotated, got %d: %v", changed, pod.values)
+	}
+	if pool["pod"][0] != initial[0] || countChanged(initial, pool["pod"]) != 0 {
+		t.Fatal("expected label pool of the config to be unchanged")
+	}
+
+	seen := map[string]struct{}{}
+	for _, v := range pod.values {
+		seen[v] = struct{}{}
+	}
+	if len(seen) != len(pod.values) {
+		t.Fatalf("expected distinct values after rotation, got %v", pod.values)
+	}
+}
+
+func countChanged(a, b []string) int {
+	changed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			changed++
+		}
+	}
+	return changed
+}
+
+func TestHierarchyChurn(t *testing.T) {
+	faker := gofakeit.New(12345)
+	h, err := newLabelHierarchy(faker, []HierarchyLevel{
+		{Name: "namespace", Values: []string{"dev", "prod"}},
+		{Name: "pod", Cardinality: 5},
+	}, map[string]string{"pod": "{{.namespace}}-{{hex 5}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := applyChurn(faker, nil, h, map[string]Churn{"namespace": {Ratio: 0.5, Iterations: 1}}, nil, start); err == nil {
+		t.Fatal("expected error for churn of a parent level")
+	}
+	if err := applyChurn(faker, nil, h, map[string]Churn{"pod": {Ratio: 0.4, Iterations: 1}}, map[string]string{"pod": "{{.namespace}}-{{hex 5}}"}, start); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := newWriteStats()
+	batch := &Batch{Streams: make(map[string]*push.Stream)}
+	for _, leaf := range h.leaves {
+		batch.stream(model.LabelSet{"namespace": leaf[0], "pod": leaf[1]}).Entries = []push.Entry{{Line: "foo"}}
+	}
+	stats.add(batch)
+
+	initial := append([][]model.LabelValue(nil), h.leaves...)
+	c := Client{rand: rand.New(rand.NewSource(1)), hierarchy: h, stats: stats}
+	c.churnLabels(start)
+
+	changed := map[model.LabelValue]int{}
+	for i, leaf := range h.leaves {
+		if leaf[0] != initial[i][0] {
+			t.Fatalf("expected parent values to be unchanged, got %v instead of %v", leaf, initial[i])
+		}
+		if !strings.HasPrefix(string(leaf[1]), string(leaf[0])+"-") {
+			t.Errorf("expected pod %s to be prefixed with namespace %s", leaf[1], leaf[0])
+		}
+		if leaf[1] != initial[i][1] {
+			changed[leaf[0]]++
+		}
+	}
+	if changed["dev"] != 2 || changed["prod"] != 2 {
+		t.Fatalf("expected 2 pods per namespace to be rotated, got %v", changed)
+	}
+	if len(stats.active) != 6 {
+		t.Fatalf("expected 6 active streams after rotation, got %d", len(stats.active))
+	}
+}
@@ -9,12 +9,12 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/xk6-loki/flog"
-	"github.com/prometheus/common/model"
 	"go.k6.io/k6/js/modules"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/netext/httpext"
@@ -27,34 +27,99 @@ const (
 	ContentEncodingSnappy = "snappy"
 	ContentEncodingGzip   = "gzip"
 
+	EncodingProtobuf = "protobuf"
+	EncodingJSON     = "json"
+
 	TenantPrefix = "xk6-tenant"
+
+	// EndpointTag is the tag name that holds the Loki endpoint
+	// a request was sent to.
+	EndpointTag = "loki_endpoint"
+)
+
+// LoadBalancing is the strategy used to select one of multiple Loki URLs for
+// each request.
+type LoadBalancing string
+
+const (
+	// RoundRobin cycles through the URLs in the order they were configured.
+	RoundRobin LoadBalancing = "round-robin"
+	// Random selects a random URL for each request.
+	Random LoadBalancing = "random"
+	// Sticky pins each VU to a single URL, based on the VU ID.
+	Sticky LoadBalancing = "sticky"
 )
 
-type labelValues struct {
-	name   model.LabelName
-	values []string
+var LoadBalancingStrategies = []LoadBalancing{RoundRobin, Random, Sticky}
+
+func (lb LoadBalancing) isValid() bool {
+	for _, s := range LoadBalancingStrategies {
+		if s == lb {
+			return true
+		}
+	}
+	return false
 }
 
 type Client struct {
-	vu      modules.VU
-	client  *http.Client
-	cfg     *Config
-	metrics lokiMetrics
-	rand    *rand.Rand
-	faker   *gofakeit.Faker
-	flog    *flog.Flog
-	labels  []labelValues
+	vu              modules.VU
+	client          *http.Client
+	cfg             *Config
+	metrics         lokiMetrics
+	rand            *rand.Rand
+	faker           *gofakeit.Faker
+	flog            *flog.Flog
+	labels          []labelValues
+	hierarchy       *labelHierarchy
+	rates           []*streamRate
+	lineLength      *lineLength
+	stacktraceRatio float64
+	traces          *tracePool
+	needles         []*needle
+	repetition      *repetition
+	patterns        *streamPatterns
+	fuzzer          *fuzzer
+	pool            *batchPool
+	poolStreams     map[*pooledStream]*instanceStream
+	jsonBatches     map[uint64]*Batch
+	next            int
+	stats           *writeStats
 }
 
 type Config struct {
-	URL           url.URL
-	UserAgent     string
-	Timeout       time.Duration
-	TenantID      string
-	Cardinalities map[string]int
-	Labels        LabelPool
-	ProtobufRatio float64
-	RandSeed      int64
+	// Deprecated: URL is the first of the URLs, use URLs instead. It is only
+	// used if URLs is empty.
+	URL             url.URL
+	URLs            []url.URL
+	LoadBalancing   LoadBalancing
+	UserAgent       string
+	Timeout         time.Duration
+	TenantID        string
+	Cardinalities   map[string]int
+	Generators      map[string]string
+	Distributions   map[string]Distribution
+	Churn           map[string]Churn
+	Hierarchy       []HierarchyLevel
+	Rates           []StreamRate
+	LineLength      *LineLength
+	StackTraceRatio float64
+	Levels          *Levels
+	Traces          *Traces
+	Needles         []Needle
+	JSON            *flog.JSONOptions
+	Logfmt          *flog.LogfmtOptions
+	Repetition      *Repetition
+	Patterns        Patterns
+	Fuzz            *Fuzz
+	Pool            *Pool
+	Labels          LabelPool
+	ProtobufRatio   float64
+	RandSeed        int64
+	Retry           RetryConfig
+
+	// generatedLabels is whether the Labels were generated by the client
+	// from the Cardinalities, instead of being set explicitly
+	generatedLabels bool
 }
 
 func (c *Client) InstantQuery(logQuery string, limit int) (httpext.Response, error) {
@@ -191,8 +256,9 @@ func (c *Client) sendQuery(q *Query) (httpext.Response, error) {
 
 	httpResp := httpext.NewResponse()
 	path := q.Endpoint()
+	endpoint := c.endpoint(state)
 
-	urlString, err := buildURL(c.cfg.URL.String(), path, q.Values().Encode())
+	urlString, err := buildURL(endpoint.String(), path, q.Values().Encode())
 	if err != nil {
 		return *httpext.NewResponse(), err
 	}
@@ -218,7 +284,7 @@ func (c *Client) sendQuery(q *Query) (httpext.Response, error) {
 		Redirects:        state.Options.MaxRedirects,
 		Timeout:          c.cfg.Timeout,
 		ResponseCallback: IsSuccessfulResponse,
-		TagsAndMeta:      state.Tags.GetCurrentValues(),
+		TagsAndMeta:      endpointTagsAndMeta(state, endpoint),
 	})
 	if err != nil {
 		return *httpResp, err
@@ -228,6 +294,16 @@ func (c *Client) sendQuery(q *Query) (httpext.Response, error) {
 }
 
 func (c *Client) Push() (httpext.Response, error) {
+	if c.pool != nil {
+		if state := c.vu.State(); state == nil {
+			return *httpext.NewResponse(), errors.New("state is nil")
+		}
+		batch, err := c.drawBatch(time.Now(), c.instance())
+		if err != nil {
+			return *httpext.NewResponse(), err
+		}
+		return c.pushBatch(batch)
+	}
 	// 5 streams per batch
 	// batch size between 800KB and 1MB
 	return c.PushParameterized(5, 800*1024, 1024*1024)
@@ -252,7 +328,10 @@ func (c *Client) PushParameterized(streams, minBatchSize, maxBatchSize int) (htt
 		return *httpext.NewResponse(), errors.New("state is nil")
 	}
 
-	batch := c.newBatch(streams, minBatchSize, maxBatchSize)
+	batch, err := c.newBatch(streams, minBatchSize, maxBatchSize)
+	if err != nil {
+		return *httpext.NewResponse(), err
+	}
 	return c.pushBatch(batch)
 }
 
@@ -263,36 +342,74 @@ func (c *Client) pushBatch(batch *Batch) (httpext.Response, error) {
 	}
 
 	var buf []byte
+	var compressionRatio float64
 	var err error
 
 	// Use snappy encoded Protobuf for 90% of the requests
 	// Use JSON encoding for 10% of the requests
 	encodeSnappy := c.rand.Float64() < c.cfg.ProtobufRatio
+	encoding := EncodingJSON
+	start := time.Now()
 	if encodeSnappy {
-		buf, _, err = batch.encodeSnappy()
+		encoding = EncodingProtobuf
+		buf, _, compressionRatio, err = batch.encodeSnappy()
 	} else {
 		buf, _, err = batch.encodeJSON()
 	}
+	encodeDuration := time.Since(start)
 	if err != nil {
 		return *httpext.NewResponse(), fmt.Errorf("failed to encode payload: %w", err)
 	}
 
-	res, err := c.send(state, buf, encodeSnappy)
-	if err != nil {
-		return *httpext.NewResponse(), fmt.Errorf("push request failed: %w", err)
-	}
-	res.Request.Body = ""
-	if IsSuccessfulResponse(res.Status) {
+	var res httpext.Response
+	for attempt := 0; ; attempt++ {
+		res, err = c.send(state, buf, encodeSnappy)
+		if err != nil {
+			// transport errors, e.g. refused connections, are retried
+			// like requests without response
+			res = *httpext.NewResponse()
+		}
+		if res.Status >= 400 {
+			c.reportRejections(res)
+		}
+		if IsSuccessfulResponse(res.Status) || attempt >= c.cfg.Retry.MaxRetries || !c.cfg.Retry.shouldRetry(res.Status) {
+			break
+		}
+		if sleepContext(c.vu.Context(), c.cfg.Retry.delay(c.rand, attempt, res.Headers, time.Now())) != nil {
+			break
+		}
+		c.reportRetry()
+	}
+	if res.Request != nil {
+		res.Request.Body = ""
+	}
+	success := err == nil && IsSuccessfulResponse(res.Status)
+	if success {
 		c.reportMetricsFromBatch(batch)
+		// batches can be pushed more than once, but Loki deduplicates the
+		// lines, so they are only written once
+		if !batch.pushed {
+			c.reportStatsFromBatch(batch)
+			c.recordNeedles(batch)
+		}
+		batch.pushed = true
+	} else {
+		c.reportDroppedBatch(batch)
 	}
+	c.reportEncodingMetrics(batch, encoding, len(buf), compressionRatio, encodeDuration, success)
+	c.reportFuzzedLines(batch, encoding, success)
 
-	return res, err
+	if err != nil {
+		return res, fmt.Errorf("push request failed: %w", err)
+	}
+	return res, nil
 }
 
 func (c *Client) send(state *lib.State, buf []byte, useProtobuf bool) (httpext.Response, error) {
 	httpResp := httpext.NewResponse()
 	path := "/loki/api/v1/push"
-	r, err := http.NewRequest(http.MethodPost, c.cfg.URL.String()+path, nil)
+	endpoint := c.endpoint(state)
+	r, err := http.NewRequest(http.MethodPost, endpoint.String()+path, nil)
 	if err != nil {
 		return *httpResp, err
 	}
@@ -311,7 +428,7 @@ func (c *Client) send(state *lib.State, buf []byte, useProtobuf bool) (httpext.R
 		r.Header.Set("Content-Type", ContentTypeJSON)
 	}
 
-	url, _ := httpext.NewURL(c.cfg.URL.String()+path, path)
+	url, _ := httpext.NewURL(endpoint.String()+path, path)
 	response, err := httpext.MakeRequest(c.vu.Context(), state, &httpext.ParsedHTTPRequest{
 		URL:              &url,
 		Req:              r,
@@ -320,7 +437,7 @@ func (c *Client) send(state *lib.State, buf []byte, useProtobuf bool) (httpext.R
 		Redirects:        state.Options.MaxRedirects,
 		Timeout:          c.cfg.Timeout,
 		ResponseCallback: IsSuccessfulResponse,
-		TagsAndMeta:      state.Tags.GetCurrentValues(),
+		TagsAndMeta:      endpointTagsAndMeta(state, endpoint),
 	})
 	if err != nil {
 		return *httpResp, err
@@ -329,6 +446,44 @@ func (c *Client) send(state *lib.State, buf []byte, useProtobuf bool) (httpext.R
 	return *response, err
 }
 
+// endpoint returns the Loki URL the next request should be sent to, based on
+// the configured load balancing strategy.
+func (c *Client) endpoint(state *lib.State) *url.URL {
+	n := len(c.cfg.URLs)
+	if n == 0 {
+		return &c.cfg.URL
+	}
+	if n == 1 {
+		return &c.cfg.URLs[0]
+	}
+	var i int
+	switch c.cfg.LoadBalancing {
+	case Random:
+		i = c.rand.Intn(n)
+	case Sticky:
+		i = int(state.VUID % uint64(n))
+	default:
+		i = c.next % n
+		c.next++
+	}
+	return &c.cfg.URLs[i]
+}
+
+// endpointTagsAndMeta returns the current tags and metadata of the VU, with
+// the Loki endpoint added as tag.
+func endpointTagsAndMeta(state *lib.State, endpoint *url.URL) metrics.TagsAndMeta {
+	ctm := state.Tags.GetCurrentValues()
+	ctm.SetTag(EndpointTag, endpointTag(endpoint))
+	return ctm
+}
+
+// endpointTag returns the scheme, host and path of the endpoint, without
+// credentials and query, so endpoints on the same host can be distinguished
+func endpointTag(endpoint *url.URL) string {
+	u := url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: endpoint.Path}
+	return u.String()
+}
+
 func IsSuccessfulResponse(n int) bool {
 	// report all 2xx respones as successful requests
 	return n/100 == 2
@@ -398,10 +553,7 @@ func (c *Client) reportMetricsFromStats(response httpext.Response, queryType Que
 }
 
 func (c *Client) reportMetricsFromBatch(batch *Batch) {
-	lines := 0
-	for _, stream := range batch.Streams {
-		lines += len(stream.Entries)
-	}
+	lines := batch.lines()
 
 	now := time.Now()
 	ctx := c.vu.Context()
@@ -430,3 +582,140 @@ func (c *Client) reportMetricsFromBatch(batch *Batch) {
 		},
 	})
 }
+
+// reportEncodingMetrics reports the size of the encoded payload, the
+// compression ratio of compressed payloads, the time it took to encode it, and
+// the shape of the batch, tagged by encoding and whether the push request was
+// successful.
+func (c *Client) reportEncodingMetrics(batch *Batch, encoding string, encodedBytes int, compressionRatio float64, encodeDuration time.Duration, success bool) {
+	now := time.Now()
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+	tags := ctm.Tags.With("encoding", encoding).With("success", strconv.FormatBool(success))
+
+	samples := make([]metrics.Sample, 0, 4+len(batch.Streams))
+	samples = append(samples,
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientEncodedBytes,
+				Tags:   tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(encodedBytes),
+			Time:     now,
+		},
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientEncodeDuration,
+				Tags:   tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    metrics.D(encodeDuration),
+			Time:     now,
+		},
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientBatchStreams,
+				Tags:   tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(len(batch.Streams)),
+			Time:     now,
+		},
+	)
+	if compressionRatio > 0 {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientCompressionRatio,
+				Tags:   tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    compressionRatio,
+			Time:     now,
+		})
+	}
+	for _, stream := range batch.Streams {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientLinesPerStream,
+				Tags:   tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(len(stream.Entries)),
+			Time:     now,
+		})
+	}
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.ConnectedSamples{Samples: samples})
+}
+
+func (c *Client) reportRetry() {
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: c.metrics.ClientRetries,
+			Tags:   ctm.Tags,
+		},
+		Metadata: ctm.Metadata,
+		Value:    1,
+		Time:     time.Now(),
+	})
+}
+
+// reportRejections reports the reasons why Loki rejected a push request,
+// based on the error message in the response body.
+func (c *Client) reportRejections(res httpext.Response) {
+	body, _ := res.Body.(string)
+	reasons := classifyPushError(body)
+
+	now := time.Now()
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+
+	samples := make([]metrics.Sample, 0, len(reasons))
+	for _, reason := range reasons {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientPushRejections,
+				Tags:   ctm.Tags.With("reason", reason),
+			},
+			Metadata: ctm.Metadata,
+			Value:    1,
+			Time:     now,
+		})
+	}
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.ConnectedSamples{Samples: samples})
+}
+
+// reportDroppedBatch reports the lines and bytes of a batch that could not be
+// pushed successfully, either because the request failed with a
+// non-retryable status or because the maximum amount of retries was reached.
+func (c *Client) reportDroppedBatch(batch *Batch) {
+	now := time.Now()
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.ConnectedSamples{
+		Samples: []metrics.Sample{
+			{
+				TimeSeries: metrics.TimeSeries{
+					Metric: c.metrics.ClientDroppedBytes,
+					Tags:   ctm.Tags,
+				},
+				Metadata: ctm.Metadata,
+				Value:    float64(batch.Bytes),
+				Time:     now,
+			},
+			{
+				TimeSeries: metrics.TimeSeries{
+					Metric: c.metrics.ClientDroppedLines,
+					Tags:   ctm.Tags,
+				},
+				Metadata: ctm.Metadata,
+				Value:    float64(batch.lines()),
+				Time:     now,
+			},
+		},
+	})
+}
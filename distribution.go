@@ -0,0 +1,94 @@
+package loki
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+const (
+	DistributionUniform  = "uniform"
+	DistributionZipf     = "zipf"
+	DistributionWeighted = "weighted"
+)
+
+// Distribution defines how often each value of a label is selected when
+// generating label sets.
+type Distribution struct {
+	// Type is one of uniform, zipf, or weighted
+	Type string
+	// S and V are the parameters of the zipf distribution, see rand.NewZipf
+	S float64
+	V float64
+	// Weights are the relative weights of the label values, in the order
+	// of the values
+	Weights []float64
+}
+
+// sampler returns the index of the next label value
+type sampler interface {
+	sample() int
+}
+
+type uniformSampler struct {
+	rand *rand.Rand
+	n    int
+}
+
+func (s *uniformSampler) sample() int {
+	return s.rand.Intn(s.n)
+}
+
+type zipfSampler struct {
+	zipf *rand.Zipf
+}
+
+func (s *zipfSampler) sample() int {
+	return int(s.zipf.Uint64())
+}
+
+type weightedSampler struct {
+	rand       *rand.Rand
+	cumulative []float64
+}
+
+func (s *weightedSampler) sample() int {
+	x := s.rand.Float64() * s.cumulative[len(s.cumulative)-1]
+	return sort.Search(len(s.cumulative), func(i int) bool { return s.cumulative[i] > x })
+}
+
+// newSampler creates a sampler for n values that follows the given
+// distribution.
+func newSampler(r *rand.Rand, d Distribution, n int) (sampler, error) {
+	switch d.Type {
+	case "", DistributionUniform:
+		return &uniformSampler{rand: r, n: n}, nil
+	case DistributionZipf:
+		if d.S <= 1 {
+			return nil, fmt.Errorf("zipf distribution requires s > 1, got %v", d.S)
+		}
+		if d.V < 1 {
+			return nil, fmt.Errorf("zipf distribution requires v >= 1, got %v", d.V)
+		}
+		return &zipfSampler{zipf: rand.NewZipf(r, d.S, d.V, uint64(n-1))}, nil
+	case DistributionWeighted:
+		if len(d.Weights) != n {
+			return nil, fmt.Errorf("weighted distribution requires %d weights, one per value, got %d", n, len(d.Weights))
+		}
+		cumulative := make([]float64, n)
+		total := 0.0
+		for i, w := range d.Weights {
+			if w < 0 {
+				return nil, fmt.Errorf("weights must not be negative, got %v", w)
+			}
+			total += w
+			cumulative[i] = total
+		}
+		if total <= 0 {
+			return nil, fmt.Errorf("the sum of the weights needs to be greater than 0")
+		}
+		return &weightedSampler{rand: r, cumulative: cumulative}, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", d.Type)
+	}
+}
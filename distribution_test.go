@@ -0,0 +1,95 @@
+package loki
+
+import (
+	"math/rand"
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/prometheus/common/model"
+)
+
+func sampleCounts(t *testing.T, d Distribution, n, samples int) []int {
+	t.Helper()
+	s, err := newSampler(rand.New(rand.NewSource(1)), d, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make([]int, n)
+	for i := 0; i < samples; i++ {
+		counts[s.sample()]++
+	}
+	return counts
+}
+
+func TestSamplerWeighted(t *testing.T) {
+	counts := sampleCounts(t, Distribution{Type: DistributionWeighted, Weights: []float64{0, 8, 2}}, 3, 10000)
+	if counts[0] != 0 {
+		t.Errorf("expected value with weight 0 to never be selected, got %d", counts[0])
+	}
+	if counts[1] < 7500 || counts[1] > 8500 {
+		t.Errorf("expected value with weight 8 to be selected ~8000 times, got %d", counts[1])
+	}
+}
+
+func TestSamplerZipf(t *testing.T) {
+	counts := sampleCounts(t, Distribution{Type: DistributionZipf, S: 2, V: 1}, 10, 10000)
+	if counts[0] < counts[1] || counts[1] < counts[9] {
+		t.Errorf("expected skewed distribution, got %v", counts)
+	}
+	if counts[0] < 5000 {
+		t.Errorf("expected first value to be selected more than half of the time, got %d", counts[0])
+	}
+}
+
+func TestSamplerErrors(t *testing.T) {
+	for name, d := range map[string]Distribution{
+		"unknown type":     {Type: "normal"},
+		"zipf s":           {Type: DistributionZipf, S: 1, V: 1},
+		"zipf v":           {Type: DistributionZipf, S: 2, V: 0},
+		"weights length":   {Type: DistributionWeighted, Weights: []float64{1, 2}},
+		"negative weights": {Type: DistributionWeighted, Weights: []float64{1, -1, 1}},
+		"zero weights":     {Type: DistributionWeighted, Weights: []float64{0, 0, 0}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newSampler(rand.New(rand.NewSource(1)), d, 3); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestHierarchyDistribution(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	h, err := newLabelHierarchy(gofakeit.New(12345), []HierarchyLevel{
+		{Name: "namespace", Values: []string{"dev", "staging", "prod"}},
+		{Name: "pod", Values: []string{"pod-a", "pod-b"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyDistributions(r, nil, h, map[string]Distribution{
+		"namespace": {Type: DistributionWeighted, Weights: []float64{0, 2, 8}},
+		"pod":       {Type: DistributionWeighted, Weights: []float64{1, 0}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[model.LabelValue]int{}
+	for i := 0; i < 10000; i++ {
+		ls := model.LabelSet{}
+		h.sample(r, ls)
+		if ls["pod"] != "pod-a" {
+			t.Fatalf("expected pod with weight 0 to never be selected, got %v", ls)
+		}
+		counts[ls["namespace"]]++
+	}
+	if counts["dev"] != 0 || counts["prod"] < 7500 || counts["prod"] > 8500 {
+		t.Errorf("expected namespaces to follow weights, got %v", counts)
+	}
+
+	if err := applyDistributions(r, nil, h, map[string]Distribution{
+		"pod": {Type: DistributionWeighted, Weights: []float64{1, 2, 3}},
+	}); err == nil {
+		t.Error("expected error for weights that do not match the values per parent")
+	}
+}
@@ -0,0 +1,97 @@
+package flog
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// minPadWords is the minimum length of the random words that are used to pad
+// lines. Longer padding repeats these words.
+const minPadWords = 256
+
+// AppendField adds a key-value pair to a log line. If the line is a JSON
+// object, the pair is added as field of the object, otherwise it is appended
+// in logfmt style.
+func AppendField(line, key, value string) string {
+	if isJSONObject(line) {
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(value)
+		var sb strings.Builder
+		sb.Grow(len(line) + len(k) + len(v) + 2)
+		body := strings.TrimRight(line[:len(line)-1], " ")
+		sb.WriteString(body)
+		if !strings.HasSuffix(body, "{") {
+			sb.WriteByte(',')
+		}
+		sb.Write(k)
+		sb.WriteByte(':')
+		sb.Write(v)
+		sb.WriteByte('}')
+		return sb.String()
+	}
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		value = quote(value)
+	}
+	return line + " " + key + "=" + value
+}
+
+// quote quotes a logfmt value
+func quote(value string) string {
+	v, _ := json.Marshal(value)
+	return string(v)
+}
+
+func isJSONObject(line string) bool {
+	return strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}")
+}
+
+// Resize pads or truncates a log line to the given size in bytes. Lines are
+// padded with a `details` field that contains random words, see AppendField.
+// Truncated lines are cut at a valid UTF-8 boundary, so they may be a few
+// bytes shorter than size, and JSON lines are no longer valid JSON.
+func (f *Flog) Resize(line string, size int) string {
+	if len(line) >= size {
+		return truncate(line, size)
+	}
+
+	// the length of the field without the value
+	overhead := len(AppendField(line, "details", "a b")) - len(line) - 3
+	if n := size - len(line) - overhead; n > 0 {
+		line = AppendField(line, "details", f.padding(n))
+	}
+	if len(line) < size {
+		line += strings.Repeat(" ", size-len(line))
+	}
+	return line
+}
+
+// padding returns n bytes of random words
+func (f *Flog) padding(n int) string {
+	var sb strings.Builder
+	sb.Grow(n)
+	for sb.Len() < n && sb.Len() < minPadWords {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(f.gofakeit.Word())
+	}
+	words := sb.String()
+	for sb.Len() < n {
+		sb.WriteByte(' ')
+		sb.WriteString(words)
+	}
+	return sb.String()[:n]
+}
+
+// truncate cuts a string to at most n bytes, without splitting a UTF-8
+// encoded rune
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
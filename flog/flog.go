@@ -8,12 +8,33 @@ import (
 )
 
 type Flog struct {
-	rand     *rand.Rand
-	gofakeit *gofakeit.Faker
+	rand       *rand.Rand
+	gofakeit   *gofakeit.Faker
+	level      LevelFunc
+	json       JSONOptions
+	jsonKeys   []string
+	logfmt     LogfmtOptions
+	logfmtKeys []string
+	patterns   *Patterns
 }
 
-func New(rand *rand.Rand, faker *gofakeit.Faker) *Flog {
-	return &Flog{rand: rand, gofakeit: faker}
+// Option configures a Flog
+type Option func(*Flog)
+
+// WithLevel sets the function that returns the level of the log line
+// generated at the given time, see Levels.
+func WithLevel(level LevelFunc) Option {
+	return func(f *Flog) {
+		f.level = level
+	}
+}
+
+func New(rand *rand.Rand, faker *gofakeit.Faker, opts ...Option) *Flog {
+	f := &Flog{rand: rand, gofakeit: faker, json: DefaultJSONOptions, logfmt: DefaultLogfmtOptions}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 func (f *Flog) LogLine(format string, t time.Time) string {
@@ -34,6 +55,32 @@ func (f *Flog) LogLine(format string, t time.Time) string {
 		return f.NewJSONLogFormat(t)
 	case "logfmt":
 		return f.NewLogFmtLogFormat(t)
+	case "logfmt_wide":
+		return f.NewWideLogfmtLog(t)
+	case "pattern":
+		return f.NewPatternLog(nil, t)
+	case "json_nested":
+		return f.NewNestedJSONLog(t)
+	case "nginx":
+		return f.NewNginxLog(t)
+	case "klog":
+		return f.NewKlogLog(t)
+	case "kubernetes_event":
+		return f.NewKubernetesEventLog(t)
+	case "envoy":
+		return f.NewEnvoyLog(t)
+	case "cef":
+		return f.NewCEFLog(t)
+	case "docker_json":
+		return f.NewDockerJSONLog(t)
+	case "stacktrace":
+		return f.NewStackTraceLog(t)
+	case "stacktrace_java":
+		return f.NewJavaStackTraceLog(t)
+	case "stacktrace_python":
+		return f.NewPythonStackTraceLog(t)
+	case "stacktrace_go":
+		return f.NewGoStackTraceLog(t)
 	default:
 		return ""
 	}
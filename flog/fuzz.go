@@ -0,0 +1,54 @@
+package flog
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// FuzzCases are the edge cases that Fuzz adds to log lines
+var FuzzCases = []string{"emoji", "rtl", "invalid_utf8", "nul", "ansi", "long_token", "quotes"}
+
+var (
+	fuzzEmoji  = []string{"😀", "🔥", "🚀", "👍🏽", "👨‍👩‍👧‍👦", "🏳️‍🌈", "❤️", "🇩🇪"}
+	fuzzRTL    = []string{"مرحبا بالعالم", "שלום עולם", "‮evil‬", "abc‏def", "؜١٢٣"}
+	fuzzBytes  = []string{"\xff", "\xfe\xff", "\xc3\x28", "\xe2\x82", "\xed\xa0\x80", "\xf0\x28\x8c\x28"}
+	fuzzANSI   = []string{"\x1b[31m", "\x1b[1;32m", "\x1b[0m", "\x1b[2K", "\x1b]0;title\x07"}
+	fuzzQuotes = []string{`"`, `\"`, `'`, `\`, `"}`, `\u0000`, "`", `""`, `\\"`}
+)
+
+// Fuzz adds the given edge case to a log line. The edge case is inserted at a
+// random position of the line, so lines in structured formats are no longer
+// valid.
+func (f *Flog) Fuzz(line, fuzzCase string) string {
+	switch fuzzCase {
+	case "emoji":
+		return f.insert(line, f.pick(fuzzEmoji))
+	case "rtl":
+		return f.insert(line, f.pick(fuzzRTL))
+	case "invalid_utf8":
+		return f.insert(line, f.pick(fuzzBytes))
+	case "nul":
+		return f.insert(line, "\x00")
+	case "ansi":
+		return f.pick(fuzzANSI) + line + "\x1b[0m"
+	case "long_token":
+		return f.insert(line, strings.Repeat(f.gofakeit.LetterN(64), f.gofakeit.Number(64, 1024)))
+	case "quotes":
+		return f.insert(line, f.pick(fuzzQuotes))
+	default:
+		return line
+	}
+}
+
+// insert inserts s at a random position of the line, without splitting a
+// UTF-8 encoded rune
+func (f *Flog) insert(line, s string) string {
+	pos := 0
+	if len(line) > 0 {
+		pos = f.rand.Intn(len(line) + 1)
+	}
+	for pos < len(line) && !utf8.RuneStart(line[pos]) {
+		pos++
+	}
+	return line[:pos] + s + line[pos:]
+}
@@ -0,0 +1,187 @@
+package flog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSONOptions configures the nested JSON log format
+type JSONOptions struct {
+	// Depth is the maximum depth of nested objects, 1 means no nesting
+	Depth int
+	// Keys is the number of keys of the top-level object, in addition to
+	// level, ts, and msg. Nested objects have half the keys of their parent.
+	Keys int
+	// KeyCardinality is the number of distinct key names
+	KeyCardinality int
+	// ArrayRatio is the ratio of values that are arrays
+	ArrayRatio float64
+	// ObjectRatio is the ratio of values that are nested objects
+	ObjectRatio float64
+}
+
+// DefaultJSONOptions are the options of the nested JSON log format, if not
+// configured
+var DefaultJSONOptions = JSONOptions{
+	Depth:          3,
+	Keys:           20,
+	KeyCardinality: 100,
+	ArrayRatio:     0.1,
+	ObjectRatio:    0.2,
+}
+
+// Validate returns an error if the options are invalid
+func (o JSONOptions) Validate() error {
+	if o.Depth < 1 {
+		return fmt.Errorf("depth needs to be at least 1, got %d", o.Depth)
+	}
+	if o.Keys < 0 {
+		return fmt.Errorf("keys must not be negative, got %d", o.Keys)
+	}
+	if o.KeyCardinality < 1 {
+		return fmt.Errorf("keyCardinality needs to be at least 1, got %d", o.KeyCardinality)
+	}
+	if o.ArrayRatio < 0 || o.ObjectRatio < 0 || o.ArrayRatio+o.ObjectRatio > 1 {
+		return fmt.Errorf("arrayRatio and objectRatio need to be between 0 and 1 in total, got %v and %v", o.ArrayRatio, o.ObjectRatio)
+	}
+	return nil
+}
+
+// WithJSONOptions sets the options of the nested JSON log format
+func WithJSONOptions(o JSONOptions) Option {
+	return func(f *Flog) {
+		f.json = o
+	}
+}
+
+// jsonEscape escapes a string, so it can be used as JSON string value in a
+// template
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// jsonField is a key-value pair of a jsonObject
+type jsonField struct {
+	Key   string
+	Value interface{}
+}
+
+// jsonObject is a JSON object that keeps the order of its keys
+type jsonObject []jsonField
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// NewNestedJSONLog creates a log string with a JSON object with nested
+// objects and arrays, see JSONOptions
+func (f *Flog) NewNestedJSONLog(t time.Time) string {
+	obj := jsonObject{
+		{"level", f.Level(t)},
+		{"ts", t.UTC().Format(time.RFC3339Nano)},
+		{"msg", f.gofakeit.HackerPhrase()},
+	}
+	obj = append(obj, f.jsonObject(f.json.Keys, 1)...)
+	b, _ := json.Marshal(obj)
+	return string(b)
+}
+
+// jsonObject returns an object with n random keys at the given depth
+func (f *Flog) jsonObject(n, depth int) jsonObject {
+	keys := f.jsonKeyPool()
+	if n > len(keys) {
+		n = len(keys)
+	}
+	obj := make(jsonObject, 0, n)
+	for _, i := range f.rand.Perm(len(keys))[:n] {
+		obj = append(obj, jsonField{keys[i], f.jsonValue(n, depth)})
+	}
+	return obj
+}
+
+// jsonValue returns a random scalar, array, or nested object. Nested objects
+// have half the keys of their parent.
+func (f *Flog) jsonValue(n, depth int) interface{} {
+	x := f.rand.Float64()
+	switch {
+	case x < f.json.ArrayRatio:
+		values := make([]interface{}, f.gofakeit.Number(1, 5))
+		for i := range values {
+			values[i] = f.jsonScalar()
+		}
+		return values
+	case x < f.json.ArrayRatio+f.json.ObjectRatio && depth < f.json.Depth:
+		return f.jsonObject(max(1, n/2), depth+1)
+	default:
+		return f.jsonScalar()
+	}
+}
+
+func (f *Flog) jsonScalar() interface{} {
+	switch f.rand.Intn(6) {
+	case 0:
+		return f.gofakeit.Number(0, 100000)
+	case 1:
+		return f.gofakeit.Float64Range(0, 1000)
+	case 2:
+		return f.gofakeit.Bool()
+	case 3:
+		return f.gofakeit.UUID()
+	case 4:
+		return f.gofakeit.IPv4Address()
+	default:
+		return f.gofakeit.HackerPhrase()
+	}
+}
+
+// jsonKeyPool returns the distinct key names of nested JSON objects, which
+// are generated on first use
+func (f *Flog) jsonKeyPool() []string {
+	if f.jsonKeys == nil {
+		f.jsonKeys = f.keyPool(f.json.KeyCardinality, map[string]struct{}{"level": {}, "ts": {}, "msg": {}})
+	}
+	return f.jsonKeys
+}
+
+// keyPool returns n distinct random key names, which are not reserved
+func (f *Flog) keyPool(n int, reserved map[string]struct{}) []string {
+	seen := make(map[string]struct{}, n+len(reserved))
+	for k := range reserved {
+		seen[k] = struct{}{}
+	}
+	keys := make([]string, 0, n)
+	for i := 0; len(keys) < n; i++ {
+		key := f.identifier()
+		if _, ok := seen[key]; ok {
+			key = fmt.Sprintf("%s_%d", key, i)
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,53 @@
+package flog
+
+import "time"
+
+// Levels are the log levels of the generated lines, from the least to the most
+// severe
+var Levels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// DefaultLevelWeights are the relative weights of the Levels, if no LevelFunc
+// is set
+var DefaultLevelWeights = []float64{1, 10, 70, 12, 6, 1}
+
+// syslogSeverities are the syslog severities of the Levels
+var syslogSeverities = map[string]int{
+	"trace": 7, // debug
+	"debug": 7, // debug
+	"info":  6, // informational
+	"warn":  4, // warning
+	"error": 3, // error
+	"fatal": 2, // critical
+}
+
+// LevelFunc returns the level of a log line generated at the given time
+type LevelFunc func(t time.Time) string
+
+// Level returns the level of a log line generated at the given time
+func (f *Flog) Level(t time.Time) string {
+	if f.level != nil {
+		return f.level(t)
+	}
+	total := 0.0
+	for _, w := range DefaultLevelWeights {
+		total += w
+	}
+	x := f.rand.Float64() * total
+	for i, w := range DefaultLevelWeights {
+		if x < w {
+			return Levels[i]
+		}
+		x -= w
+	}
+	return Levels[len(Levels)-1]
+}
+
+// SyslogPriority returns the syslog priority of a log line with the given
+// level, with a random facility
+func (f *Flog) SyslogPriority(level string) int {
+	severity, ok := syslogSeverities[level]
+	if !ok {
+		severity = syslogSeverities["info"]
+	}
+	return f.rand.Intn(24)*8 + severity
+}
@@ -1,6 +1,7 @@
 package flog
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -19,10 +20,18 @@ const (
 	RFC5424Log = "<%d>%d %s %s %s %d ID%d %s %s"
 	// CommonLogFormat : {host} {user-identifier} {auth-user-id} [{datetime}] "{method} {request} {protocol}" {response-code} {bytes}
 	CommonLogFormat = "%s - %s [%s] \"%s %s %s\" %d %d"
-	// JSONLogFormat : {"host": "{host}", "user-identifier": "{user-identifier}", "datetime": "{datetime}", "method": "{method}", "request": "{request}", "protocol": "{protocol}", "status", {status}, "bytes": {bytes}, "referer": "{referer}"}
-	JSONLogFormat = `{"host":"%s", "user-identifier":"%s", "datetime":"%s", "method": "%s", "request": "%s", "protocol":"%s", "status":%d, "bytes":%d, "referer": "%s"}`
-	// LogFmtLogFormat : host={host} user={user-identifier} timestamp={datetime} method={method} request="{request}" protocol={protocol} status={status} bytes={bytes} referer="{referer}"
-	LogFmtLogFormat = `host="%s" user=%s timestamp=%s method=%s request="%s" protocol=%s status=%d bytes=%d referer="%s"`
+	// JSONLogFormat : {"level": "{level}", "host": "{host}", "user-identifier": "{user-identifier}", "datetime": "{datetime}", "method": "{method}", "request": "{request}", "protocol": "{protocol}", "status", {status}, "bytes": {bytes}, "referer": "{referer}"}
+	JSONLogFormat = `{"level":"%s", "host":"%s", "user-identifier":"%s", "datetime":"%s", "method": "%s", "request": "%s", "protocol":"%s", "status":%d, "bytes":%d, "referer": "%s"}`
+	// LogFmtLogFormat : level={level} host={host} user={user-identifier} timestamp={datetime} method={method} request="{request}" protocol={protocol} status={status} bytes={bytes} referer="{referer}"
+	LogFmtLogFormat = `level=%s host="%s" user=%s timestamp=%s method=%s request="%s" protocol=%s status=%d bytes=%d referer="%s"`
+	// NginxLog : {remote-addr} - {remote-user} [{time-local}] "{method} {request} {protocol}" {status} {bytes} "{referer}" "{agent}" rt={request-time} uct="{upstream-connect-time}" uht="{upstream-header-time}" urt="{upstream-response-time}"
+	NginxLog = "%s - %s [%s] \"%s %s %s\" %d %d \"%s\" \"%s\" rt=%.3f uct=\"%.3f\" uht=\"%.3f\" urt=\"%.3f\""
+	// KlogLog : {severity}{mmdd hh:mm:ss.uuuuuu} {thread-id} {file}:{line}] {message}
+	KlogLog = "%s%s %7d %s.go:%d] %s"
+	// EnvoyLog : [{start-time}] "{method} {path} {protocol}" {response-code} {response-flags} {bytes-received} {bytes-sent} {duration} {upstream-service-time} "{x-forwarded-for}" "{user-agent}" "{request-id}" "{authority}" "{upstream-host}"
+	EnvoyLog = "[%s] \"%s %s %s\" %d %s %d %d %d %d \"%s\" \"%s\" \"%s\" \"%s\" \"%s:%d\""
+	// CEFLog : {timestamp} {hostname} CEF:0|{vendor}|{product}|{version}|{signature-id}|{name}|{severity}|rt={receipt-time} src={source} spt={source-port} dst={destination} dpt={destination-port} proto={protocol} act={action} suser={user}
+	CEFLog = "%s %s CEF:0|%s|%s|%s|%d|%s|%d|rt=%d src=%s spt=%d dst=%s dpt=%d proto=%s act=%s suser=%s"
 )
 
 // NewApacheCommonLog creates a log string with apache common log format
@@ -76,7 +85,7 @@ func (f *Flog) NewApacheErrorLog(t time.Time) string {
 func (f *Flog) NewRFC3164Log(t time.Time) string {
 	return fmt.Sprintf(
 		RFC3164Log,
-		f.gofakeit.Number(0, 191),
+		f.SyslogPriority(f.Level(t)),
 		t.Format(RFC3164),
 		strings.ToLower(f.gofakeit.Username()),
 		f.gofakeit.Word(),
@@ -89,7 +98,7 @@ func (f *Flog) NewRFC3164Log(t time.Time) string {
 func (f *Flog) NewRFC5424Log(t time.Time) string {
 	return fmt.Sprintf(
 		RFC5424Log,
-		f.gofakeit.Number(0, 191),
+		f.SyslogPriority(f.Level(t)),
 		f.gofakeit.Number(1, 3),
 		t.Format(RFC5424),
 		f.gofakeit.DomainName(),
@@ -120,15 +129,16 @@ func (f *Flog) NewCommonLogFormat(t time.Time) string {
 func (f *Flog) NewJSONLogFormat(t time.Time) string {
 	return fmt.Sprintf(
 		JSONLogFormat,
+		f.Level(t),
 		f.gofakeit.IPv4Address(),
-		f.RandAuthUserID(),
+		jsonEscape(f.RandAuthUserID()),
 		t.Format(CommonLog),
 		f.gofakeit.HTTPMethod(),
-		f.RandResourceURI(),
+		jsonEscape(f.RandResourceURI()),
 		f.RandHTTPVersion(),
 		f.gofakeit.HTTPStatusCodeSimple(),
 		f.gofakeit.Number(0, 30000),
-		f.gofakeit.URL(),
+		jsonEscape(f.gofakeit.URL()),
 	)
 }
 
@@ -136,6 +146,7 @@ func (f *Flog) NewJSONLogFormat(t time.Time) string {
 func (f *Flog) NewLogFmtLogFormat(t time.Time) string {
 	return fmt.Sprintf(
 		LogFmtLogFormat,
+		f.Level(t),
 		f.gofakeit.IPv4Address(),
 		f.RandAuthUserID(),
 		t.Format(RFC5424),
@@ -147,3 +158,207 @@ func (f *Flog) NewLogFmtLogFormat(t time.Time) string {
 		f.gofakeit.URL(),
 	)
 }
+
+// NewNginxLog creates a log string with nginx access log format, including
+// the request and upstream timings
+func (f *Flog) NewNginxLog(t time.Time) string {
+	upstreamConnect := float64(f.gofakeit.Number(0, 50)) / 1000
+	upstreamHeader := upstreamConnect + float64(f.gofakeit.Number(1, 2000))/1000
+	upstreamResponse := upstreamHeader + float64(f.gofakeit.Number(0, 500))/1000
+	return fmt.Sprintf(
+		NginxLog,
+		f.gofakeit.IPv4Address(),
+		f.RandAuthUserID(),
+		t.Format(CommonLog),
+		f.gofakeit.HTTPMethod(),
+		f.RandResourceURI(),
+		f.RandHTTPVersion(),
+		f.gofakeit.HTTPStatusCodeSimple(),
+		f.gofakeit.Number(0, 30000),
+		f.gofakeit.URL(),
+		f.gofakeit.UserAgent(),
+		upstreamResponse+float64(f.gofakeit.Number(0, 20))/1000,
+		upstreamConnect,
+		upstreamHeader,
+		upstreamResponse,
+	)
+}
+
+// NewKlogLog creates a log string with klog format, which is used by the
+// Kubernetes components
+func (f *Flog) NewKlogLog(t time.Time) string {
+	return fmt.Sprintf(
+		KlogLog,
+		klogSeverity(f.Level(t)),
+		t.Format(Klog),
+		f.gofakeit.Number(1, 65535),
+		f.identifier(),
+		f.gofakeit.Number(1, 2000),
+		f.gofakeit.HackerPhrase(),
+	)
+}
+
+// klogSeverity returns the klog severity of a level
+func klogSeverity(level string) string {
+	switch level {
+	case "warn":
+		return "W"
+	case "error":
+		return "E"
+	case "fatal":
+		return "F"
+	default:
+		return "I"
+	}
+}
+
+// NewKubernetesEventLog creates a log string with a Kubernetes event in JSON
+// format, as exported by event exporters
+func (f *Flog) NewKubernetesEventLog(t time.Time) string {
+	type objectReference struct {
+		Kind      string `json:"kind"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+		UID       string `json:"uid"`
+	}
+	type event struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"metadata"`
+		InvolvedObject objectReference `json:"involvedObject"`
+		Reason         string          `json:"reason"`
+		Message        string          `json:"message"`
+		Type           string          `json:"type"`
+		Count          int             `json:"count"`
+		FirstTimestamp string          `json:"firstTimestamp"`
+		LastTimestamp  string          `json:"lastTimestamp"`
+		Source         struct {
+			Component string `json:"component"`
+			Host      string `json:"host"`
+		} `json:"source"`
+	}
+
+	reasons := []string{"Scheduled", "Pulling", "Pulled", "Created", "Started", "Killing", "BackOff", "Unhealthy", "FailedScheduling", "FailedMount"}
+	reason := f.pick(reasons)
+	eventType := "Normal"
+	if strings.HasPrefix(reason, "Failed") || reason == "BackOff" || reason == "Unhealthy" {
+		eventType = "Warning"
+	}
+	namespace := f.identifier()
+	pod := fmt.Sprintf("%s-%x-%s", f.identifier(), f.gofakeit.Number(0x10000000, 0xffffffff), strings.ToLower(f.gofakeit.LetterN(5)))
+
+	e := event{
+		Kind: "Event",
+		InvolvedObject: objectReference{
+			Kind:      "Pod",
+			Namespace: namespace,
+			Name:      pod,
+			UID:       f.gofakeit.UUID(),
+		},
+		Reason:         reason,
+		Message:        f.gofakeit.HackerPhrase(),
+		Type:           eventType,
+		Count:          f.gofakeit.Number(1, 100),
+		FirstTimestamp: t.Add(-time.Duration(f.gofakeit.Number(0, 3600)) * time.Second).UTC().Format(time.RFC3339),
+		LastTimestamp:  t.UTC().Format(time.RFC3339),
+	}
+	e.Metadata.Name = fmt.Sprintf("%s.%x", pod, t.UnixNano())
+	e.Metadata.Namespace = namespace
+	e.Metadata.UID = f.gofakeit.UUID()
+	e.Source.Component = "kubelet"
+	e.Source.Host = f.gofakeit.DomainName()
+
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// NewEnvoyLog creates a log string with the default Envoy access log format
+func (f *Flog) NewEnvoyLog(t time.Time) string {
+	flags := []string{"-", "-", "-", "UH", "UF", "UO", "NR", "URX", "DC"}
+	return fmt.Sprintf(
+		EnvoyLog,
+		t.UTC().Format(RFC5424),
+		f.gofakeit.HTTPMethod(),
+		f.RandResourceURI(),
+		f.RandHTTPVersion(),
+		f.gofakeit.HTTPStatusCodeSimple(),
+		f.pick(flags),
+		f.gofakeit.Number(0, 30000),
+		f.gofakeit.Number(0, 30000),
+		f.gofakeit.Number(1, 5000),
+		f.gofakeit.Number(1, 5000),
+		f.gofakeit.IPv4Address(),
+		f.gofakeit.UserAgent(),
+		f.gofakeit.UUID(),
+		f.gofakeit.DomainName(),
+		f.gofakeit.IPv4Address(),
+		f.gofakeit.Number(1, 65535),
+	)
+}
+
+// NewCEFLog creates a log string with a security event in Common Event Format
+// (CEF), with a syslog header
+func (f *Flog) NewCEFLog(t time.Time) string {
+	events := []string{"Port scan detected", "Malware blocked", "Brute force attempt", "Policy violation", "Suspicious login", "Worm successfully stopped"}
+	actions := []string{"blocked", "allowed", "quarantined", "alerted"}
+	return fmt.Sprintf(
+		CEFLog,
+		t.Format(RFC3164),
+		f.gofakeit.DomainName(),
+		f.gofakeit.Company(),
+		f.typeName(),
+		f.gofakeit.AppVersion(),
+		f.gofakeit.Number(100, 999),
+		f.pick(events),
+		cefSeverity(f.Level(t)),
+		t.UnixMilli(),
+		f.gofakeit.IPv4Address(),
+		f.gofakeit.Number(1024, 65535),
+		f.gofakeit.IPv4Address(),
+		f.gofakeit.Number(1, 1024),
+		f.pick([]string{"TCP", "UDP"}),
+		f.pick(actions),
+		strings.ToLower(f.gofakeit.Username()),
+	)
+}
+
+// cefSeverity returns the CEF severity (0-10) of a level
+func cefSeverity(level string) int {
+	switch level {
+	case "warn":
+		return 5
+	case "error":
+		return 8
+	case "fatal":
+		return 10
+	default:
+		return 2
+	}
+}
+
+// NewDockerJSONLog creates a log string with the format of the Docker
+// json-file logging driver, which wraps a JSON, logfmt or common log line
+func (f *Flog) NewDockerJSONLog(t time.Time) string {
+	var line string
+	switch f.rand.Intn(3) {
+	case 0:
+		line = f.NewJSONLogFormat(t)
+	case 1:
+		line = f.NewLogFmtLogFormat(t)
+	default:
+		line = f.NewCommonLogFormat(t)
+	}
+	b, _ := json.Marshal(struct {
+		Log    string `json:"log"`
+		Stream string `json:"stream"`
+		Time   string `json:"time"`
+	}{
+		Log:    line + "\n",
+		Stream: f.pick([]string{"stdout", "stderr"}),
+		Time:   t.UTC().Format(time.RFC3339Nano),
+	})
+	return string(b)
+}
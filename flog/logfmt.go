@@ -0,0 +1,158 @@
+package flog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtOptions configures the wide logfmt log format
+type LogfmtOptions struct {
+	// Keys is the number of keys of each line, in addition to level, ts,
+	// and msg
+	Keys int
+	// KeyNames are the names of the keys. If empty, KeyCardinality key
+	// names are generated.
+	KeyNames       []string
+	KeyCardinality int
+	// Cardinalities are the number of distinct values of keys with
+	// high-cardinality values, e.g. request or user IDs. These keys are part
+	// of every line.
+	Cardinalities map[string]int
+}
+
+// DefaultLogfmtOptions are the options of the wide logfmt log format, if not
+// configured
+var DefaultLogfmtOptions = LogfmtOptions{
+	Keys:           20,
+	KeyCardinality: 100,
+	Cardinalities: map[string]int{
+		"request_id": 1000000,
+		"user_id":    10000,
+	},
+}
+
+// Validate returns an error if the options are invalid
+func (o LogfmtOptions) Validate() error {
+	if o.Keys < 0 {
+		return fmt.Errorf("keys must not be negative, got %d", o.Keys)
+	}
+	if len(o.KeyNames) == 0 && o.KeyCardinality < 1 {
+		return fmt.Errorf("keyCardinality needs to be at least 1, got %d", o.KeyCardinality)
+	}
+	for _, k := range o.KeyNames {
+		if !isLogfmtKey(k) {
+			return fmt.Errorf("invalid key %q", k)
+		}
+	}
+	for k, n := range o.Cardinalities {
+		if !isLogfmtKey(k) {
+			return fmt.Errorf("invalid key %q", k)
+		}
+		if n < 1 {
+			return fmt.Errorf("cardinality of key %q needs to be at least 1, got %d", k, n)
+		}
+	}
+	if len(o.Cardinalities) > o.Keys {
+		return fmt.Errorf("keys needs to be at least the number of keys with cardinalities (%d), got %d", len(o.Cardinalities), o.Keys)
+	}
+	return nil
+}
+
+func isLogfmtKey(k string) bool {
+	return k != "" && !strings.ContainsAny(k, " =\"\t\n")
+}
+
+// WithLogfmtOptions sets the options of the wide logfmt log format
+func WithLogfmtOptions(o LogfmtOptions) Option {
+	return func(f *Flog) {
+		f.logfmt = o
+	}
+}
+
+// NewWideLogfmtLog creates a log string in logfmt format with many keys, see
+// LogfmtOptions
+func (f *Flog) NewWideLogfmtLog(t time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("level=")
+	sb.WriteString(f.Level(t))
+	sb.WriteString(" ts=")
+	sb.WriteString(t.UTC().Format(time.RFC3339Nano))
+	sb.WriteString(" msg=")
+	sb.WriteString(quote(f.gofakeit.HackerPhrase()))
+
+	// keys with cardinalities are part of every line, sorted so the lines
+	// are deterministic
+	keys := make([]string, 0, len(f.logfmt.Cardinalities))
+	for k := range f.logfmt.Cardinalities {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteByte(' ')
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(cardinalityValue(k, f.rand.Intn(f.logfmt.Cardinalities[k])))
+	}
+
+	pool := f.logfmtKeyPool()
+	n := min(f.logfmt.Keys-len(keys), len(pool))
+	for _, i := range f.rand.Perm(len(pool))[:n] {
+		sb.WriteByte(' ')
+		sb.WriteString(pool[i])
+		sb.WriteByte('=')
+		sb.WriteString(f.logfmtValue())
+	}
+	return sb.String()
+}
+
+// cardinalityValue returns the n-th value of a high-cardinality key, which
+// looks like a random ID
+func cardinalityValue(key string, n int) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte(strconv.Itoa(n)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func (f *Flog) logfmtValue() string {
+	switch f.rand.Intn(5) {
+	case 0:
+		return strconv.Itoa(f.gofakeit.Number(0, 100000))
+	case 1:
+		return strconv.FormatFloat(f.gofakeit.Float64Range(0, 1000), 'f', 3, 64)
+	case 2:
+		return strconv.FormatBool(f.gofakeit.Bool())
+	case 3:
+		return f.gofakeit.IPv4Address()
+	default:
+		return quote(f.gofakeit.HackerPhrase())
+	}
+}
+
+// logfmtKeyPool returns the key names of the wide logfmt format, which are
+// generated on first use if not configured. Keys with cardinalities are
+// excluded.
+func (f *Flog) logfmtKeyPool() []string {
+	if f.logfmtKeys != nil {
+		return f.logfmtKeys
+	}
+	reserved := map[string]struct{}{"level": {}, "ts": {}, "msg": {}}
+	for k := range f.logfmt.Cardinalities {
+		reserved[k] = struct{}{}
+	}
+	keys := f.logfmt.KeyNames
+	if len(keys) == 0 {
+		keys = f.keyPool(f.logfmt.KeyCardinality, reserved)
+	}
+	f.logfmtKeys = make([]string, 0, len(keys))
+	for _, k := range keys {
+		if _, ok := reserved[k]; !ok {
+			f.logfmtKeys = append(f.logfmtKeys, k)
+		}
+	}
+	return f.logfmtKeys
+}
@@ -0,0 +1,120 @@
+package flog
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+const (
+	DefaultPatternTemplates = 10
+	DefaultPatternSlots     = 3
+)
+
+// patternSlots are the types of the variable slots of pattern templates
+var patternSlots = []string{"ip", "number", "uuid", "duration", "word", "path"}
+
+// Patterns are the templates of the pattern log format. Each template consists
+// of fixed words and variable slots.
+type Patterns struct {
+	templates [][]patternToken
+}
+
+// patternToken is either a fixed word or a variable slot of a template
+type patternToken struct {
+	word string
+	slot string
+}
+
+// NewPatterns creates n templates with the given number of variable slots.
+// The templates only depend on the seed, so the same seed results in the same
+// templates.
+func NewPatterns(seed int64, n, slots int) *Patterns {
+	r := rand.New(rand.NewSource(seed))
+	faker := gofakeit.NewCustom(r)
+	p := &Patterns{templates: make([][]patternToken, 0, n)}
+	seen := map[string]struct{}{}
+	for len(p.templates) < n {
+		words := faker.Number(3, 8)
+		tokens := make([]patternToken, 0, words+slots)
+		for i := 0; i < words; i++ {
+			tokens = append(tokens, patternToken{word: strings.ToLower(faker.Word())})
+		}
+		for i := 0; i < slots; i++ {
+			// insert the slot after a random word, but never at the
+			// start, so templates start with a fixed word
+			pos := 1 + r.Intn(len(tokens))
+			tokens = append(tokens[:pos], append([]patternToken{{slot: patternSlots[r.Intn(len(patternSlots))]}}, tokens[pos:]...)...)
+		}
+		template := templateString(tokens)
+		if _, ok := seen[template]; ok {
+			continue
+		}
+		seen[template] = struct{}{}
+		p.templates = append(p.templates, tokens)
+	}
+	return p
+}
+
+// Templates returns the templates, with `<_>` for the timestamp and the
+// variable slots, as returned by the pattern queries of Loki
+func (p *Patterns) Templates() []string {
+	result := make([]string, 0, len(p.templates))
+	for _, tokens := range p.templates {
+		result = append(result, "<_> "+templateString(tokens))
+	}
+	return result
+}
+
+func templateString(tokens []patternToken) string {
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = token.word
+		if token.slot != "" {
+			words[i] = "<_>"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// NewPatternLog creates a log string from a random template of the patterns
+func (f *Flog) NewPatternLog(p *Patterns, t time.Time) string {
+	if p == nil {
+		if f.patterns == nil {
+			f.patterns = NewPatterns(0, DefaultPatternTemplates, DefaultPatternSlots)
+		}
+		p = f.patterns
+	}
+	tokens := p.templates[f.rand.Intn(len(p.templates))]
+	var sb strings.Builder
+	sb.WriteString(t.Format(RFC5424))
+	for _, token := range tokens {
+		sb.WriteByte(' ')
+		if token.slot == "" {
+			sb.WriteString(token.word)
+			continue
+		}
+		sb.WriteString(f.slotValue(token.slot))
+	}
+	return sb.String()
+}
+
+func (f *Flog) slotValue(slot string) string {
+	switch slot {
+	case "ip":
+		return f.gofakeit.IPv4Address()
+	case "number":
+		return fmt.Sprint(f.gofakeit.Number(0, 100000))
+	case "uuid":
+		return f.gofakeit.UUID()
+	case "duration":
+		return (time.Duration(f.gofakeit.Number(1, 10000)) * time.Millisecond).String()
+	case "path":
+		return f.RandResourceURI()
+	default:
+		return f.identifier()
+	}
+}
@@ -0,0 +1,230 @@
+package flog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// StackTraceLog : {iso-timestamp} ERROR [{thread}] {logger} - {message}
+	StackTraceLog = "%s ERROR [%s] %s - %s"
+	// PythonStackTraceLog : {timestamp} ERROR {logger}: {message}
+	PythonStackTraceLog = "%s ERROR %s: %s"
+)
+
+var (
+	javaExceptions = []string{
+		"java.lang.NullPointerException",
+		"java.lang.IllegalStateException",
+		"java.lang.IllegalArgumentException",
+		"java.lang.IndexOutOfBoundsException",
+		"java.util.ConcurrentModificationException",
+		"java.io.IOException",
+		"java.net.SocketTimeoutException",
+		"java.sql.SQLException",
+	}
+	pythonExceptions = []string{
+		"ValueError",
+		"KeyError",
+		"TypeError",
+		"AttributeError",
+		"IndexError",
+		"RuntimeError",
+		"ConnectionError",
+		"TimeoutError",
+	}
+	goPanics = []string{
+		"runtime error: invalid memory address or nil pointer dereference",
+		"runtime error: index out of range [%d] with length %d",
+		"runtime error: slice bounds out of range [:%d] with capacity %d",
+		"assignment to entry in nil map",
+		"send on closed channel",
+	}
+)
+
+// NewStackTraceLog creates a multiline log string with a Java, Python or Go
+// stack trace
+func (f *Flog) NewStackTraceLog(t time.Time) string {
+	switch f.rand.Intn(3) {
+	case 0:
+		return f.NewJavaStackTraceLog(t)
+	case 1:
+		return f.NewPythonStackTraceLog(t)
+	default:
+		return f.NewGoStackTraceLog(t)
+	}
+}
+
+// NewJavaStackTraceLog creates a multiline log string with a Java stack trace
+func (f *Flog) NewJavaStackTraceLog(t time.Time) string {
+	return fmt.Sprintf(
+		StackTraceLog,
+		t.Format(RFC5424),
+		f.javaThread(),
+		f.javaClass(),
+		f.gofakeit.HackerPhrase(),
+	) + "\n" + f.JavaStackTrace()
+}
+
+// NewPythonStackTraceLog creates a multiline log string with a Python
+// traceback
+func (f *Flog) NewPythonStackTraceLog(t time.Time) string {
+	return fmt.Sprintf(
+		PythonStackTraceLog,
+		t.Format(RFC5424),
+		strings.ToLower(f.gofakeit.Noun()),
+		f.gofakeit.HackerPhrase(),
+	) + "\n" + f.PythonStackTrace()
+}
+
+// NewGoStackTraceLog creates a multiline log string with a Go panic
+func (f *Flog) NewGoStackTraceLog(t time.Time) string {
+	// the first line of the trace is the panic message
+	return t.Format(RFC5424) + " " + f.GoStackTrace()
+}
+
+// RandStackTrace returns a random Java, Python or Go stack trace
+func (f *Flog) RandStackTrace() string {
+	switch f.rand.Intn(3) {
+	case 0:
+		return f.JavaStackTrace()
+	case 1:
+		return f.PythonStackTrace()
+	default:
+		return f.GoStackTrace()
+	}
+}
+
+// WithStackTrace adds a random stack trace to a log line in the given format.
+// The stack trace is added as `stacktrace` field to JSON and logfmt lines, see
+// AppendField, and as additional lines to all other lines.
+func (f *Flog) WithStackTrace(format, line string) string {
+	if strings.HasPrefix(format, "logfmt") || isJSONObject(line) {
+		return AppendField(line, "stacktrace", f.RandStackTrace())
+	}
+	return line + "\n" + f.RandStackTrace()
+}
+
+// JavaStackTrace returns a Java stack trace, optionally with a cause
+func (f *Flog) JavaStackTrace() string {
+	var sb strings.Builder
+	frames := f.javaFrames(f.gofakeit.Number(5, 25))
+	fmt.Fprintf(&sb, "%s: %s", f.pick(javaExceptions), f.gofakeit.HackerPhrase())
+	for _, frame := range frames {
+		sb.WriteString("\n\tat ")
+		sb.WriteString(frame)
+	}
+	if f.rand.Intn(2) == 0 {
+		// the cause shares the outer frames with the exception
+		causeFrames := f.javaFrames(f.gofakeit.Number(2, 8))
+		fmt.Fprintf(&sb, "\nCaused by: %s: %s", f.pick(javaExceptions), f.gofakeit.HackerPhrase())
+		for _, frame := range causeFrames {
+			sb.WriteString("\n\tat ")
+			sb.WriteString(frame)
+		}
+		fmt.Fprintf(&sb, "\n\t... %d more", len(frames))
+	}
+	return sb.String()
+}
+
+// PythonStackTrace returns a Python traceback
+func (f *Flog) PythonStackTrace() string {
+	var sb strings.Builder
+	sb.WriteString("Traceback (most recent call last):")
+	for i, n := 0, f.gofakeit.Number(3, 15); i < n; i++ {
+		fmt.Fprintf(
+			&sb,
+			"\n  File \"/app/%s/%s.py\", line %d, in %s\n    %s = %s(%s)",
+			f.identifier(),
+			f.identifier(),
+			f.gofakeit.Number(1, 2000),
+			f.identifier(),
+			f.identifier(),
+			f.identifier(),
+			f.identifier(),
+		)
+	}
+	fmt.Fprintf(&sb, "\n%s: %s", f.pick(pythonExceptions), f.gofakeit.HackerPhrase())
+	return sb.String()
+}
+
+// GoStackTrace returns a Go panic with the stack traces of one or more
+// goroutines
+func (f *Flog) GoStackTrace() string {
+	var sb strings.Builder
+	msg := f.pick(goPanics)
+	if strings.Contains(msg, "%d") {
+		msg = fmt.Sprintf(msg, f.gofakeit.Number(1, 100), f.gofakeit.Number(0, 100))
+	}
+	fmt.Fprintf(&sb, "panic: %s\n", msg)
+	if strings.HasPrefix(msg, "runtime error: invalid memory") {
+		fmt.Fprintf(&sb, "[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x%x]\n", f.gofakeit.Number(0x400000, 0xffffff))
+	}
+	for g, n := 0, f.gofakeit.Number(1, 3); g < n; g++ {
+		state := "running"
+		if g > 0 {
+			state = "chan receive"
+		}
+		fmt.Fprintf(&sb, "\ngoroutine %d [%s]:", f.gofakeit.Number(1, 10000), state)
+		for i, frames := 0, f.gofakeit.Number(3, 12); i < frames; i++ {
+			pkg := f.identifier()
+			fmt.Fprintf(
+				&sb,
+				"\ngithub.com/%s/%s/pkg/%s.(*%s).%s(0xc%09x)\n\t/app/pkg/%s/%s.go:%d +0x%x",
+				f.identifier(),
+				f.identifier(),
+				pkg,
+				f.typeName(),
+				f.typeName(),
+				f.gofakeit.Number(0, 0xfffffff),
+				pkg,
+				f.identifier(),
+				f.gofakeit.Number(1, 2000),
+				f.gofakeit.Number(0x10, 0xfff),
+			)
+		}
+		if g < n-1 {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func (f *Flog) javaFrames(n int) []string {
+	frames := make([]string, n)
+	for i := range frames {
+		class := f.typeName() + "Service"
+		frames[i] = fmt.Sprintf("com.%s.%s.%s.%s(%s.java:%d)", f.identifier(), f.identifier(), class, f.identifier(), class, f.gofakeit.Number(1, 2000))
+	}
+	return frames
+}
+
+// javaClass returns a fully qualified Java class name
+func (f *Flog) javaClass() string {
+	return fmt.Sprintf("com.%s.%s.%sService", f.identifier(), f.identifier(), f.typeName())
+}
+
+func (f *Flog) javaThread() string {
+	threads := []string{"main", "http-nio-8080-exec-%d", "pool-1-thread-%d", "ForkJoinPool.commonPool-worker-%d"}
+	thread := f.pick(threads)
+	if strings.Contains(thread, "%d") {
+		thread = fmt.Sprintf(thread, f.gofakeit.Number(1, 200))
+	}
+	return thread
+}
+
+// identifier returns a lower case identifier, e.g. a package or function name
+func (f *Flog) identifier() string {
+	return strings.ToLower(strings.ReplaceAll(f.gofakeit.Noun(), " ", "_"))
+}
+
+// typeName returns a capitalized identifier, e.g. a class or type name
+func (f *Flog) typeName() string {
+	id := strings.ReplaceAll(f.identifier(), "_", "")
+	return strings.ToUpper(id[:1]) + id[1:]
+}
+
+func (f *Flog) pick(values []string) string {
+	return values[f.rand.Intn(len(values))]
+}
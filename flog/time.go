@@ -7,4 +7,5 @@ const (
 	RFC3164     = "Jan 02 15:04:05"
 	RFC5424     = "2006-01-02T15:04:05.000Z"
 	CommonLog   = "02/Jan/2006:15:04:05 -0700"
+	Klog        = "0102 15:04:05.000000"
 )
@@ -0,0 +1,41 @@
+package loki
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+func TestLogFormats(t *testing.T) {
+	faker := gofakeit.New(12345)
+	f := flog.New(faker.Rand, faker)
+	now := time.Now()
+
+	for _, format := range LabelValuesFormat {
+		if line := f.LogLine(format, now); line == "" {
+			t.Errorf("expected a log line in format %s", format)
+		}
+	}
+
+	for _, format := range []string{"json", "kubernetes_event", "docker_json"} {
+		line := f.LogLine(format, now)
+		if !json.Valid([]byte(line)) {
+			t.Errorf("expected valid JSON in format %s, got %s", format, line)
+		}
+	}
+
+	for format, pattern := range map[string]string{
+		"nginx": `^\S+ - \S+ \[[^\]]+\] "\S+ \S+ \S+" \d+ \d+ "[^"]*" "[^"]*" rt=\d+\.\d{3} uct="\d+\.\d{3}" uht="\d+\.\d{3}" urt="\d+\.\d{3}"$`,
+		"klog":  `^[IWEF]\d{4} \d{2}:\d{2}:\d{2}\.\d{6} +\d+ \w+\.go:\d+\] `,
+		"envoy": `^\[[^\]]+\] "\S+ \S+ \S+" \d+ \S+ \d+ \d+ \d+ \d+ "[^"]*" "[^"]*" "[^"]*" "[^"]*" "[^"]*"$`,
+		"cef":   `CEF:0\|[^|]+\|[^|]+\|[^|]+\|\d+\|[^|]+\|\d+\|rt=\d+ src=`,
+	} {
+		if line := f.LogLine(format, now); !regexp.MustCompile(pattern).MatchString(line) {
+			t.Errorf("expected line in format %s to match %s, got %s", format, pattern, line)
+		}
+	}
+}
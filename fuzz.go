@@ -0,0 +1,96 @@
+package loki
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	"go.k6.io/k6/metrics"
+)
+
+// Fuzz defines the edge cases, e.g. emoji or invalid UTF-8, that are added to
+// log lines, see flog.FuzzCases.
+type Fuzz struct {
+	// Ratio is the ratio of log lines with an edge case
+	Ratio float64
+	// Cases are the edge cases that are added to the lines. One random case
+	// is added to each fuzzed line.
+	Cases []string
+}
+
+// fuzzer keeps track of the fuzzed lines per case of the batch that is
+// generated
+type fuzzer struct {
+	Fuzz
+	lines map[string]int
+}
+
+func newFuzzer(f Fuzz) (*fuzzer, error) {
+	if f.Ratio < 0 || f.Ratio > 1 {
+		return nil, fmt.Errorf("ratio needs to be between 0 and 1, got %v", f.Ratio)
+	}
+	if len(f.Cases) == 0 {
+		f.Cases = flog.FuzzCases
+	}
+	for _, c := range f.Cases {
+		if !slices.Contains(flog.FuzzCases, c) {
+			return nil, fmt.Errorf("unknown case %q, must be one of %v", c, flog.FuzzCases)
+		}
+	}
+	return &fuzzer{Fuzz: f, lines: map[string]int{}}, nil
+}
+
+// fuzz adds a random edge case to the given ratio of entries
+func (c *Client) fuzz(entry *push.Entry) {
+	if c.fuzzer == nil || c.rand.Float64() >= c.fuzzer.Ratio {
+		return
+	}
+	fuzzCase := c.fuzzer.Cases[c.rand.Intn(len(c.fuzzer.Cases))]
+	entry.Line = c.flog.Fuzz(entry.Line, fuzzCase)
+	c.fuzzer.lines[fuzzCase]++
+}
+
+// collectFuzzedLines moves the number of fuzzed lines per case to the batch
+func (c *Client) collectFuzzedLines(batch *Batch) {
+	if c.fuzzer == nil || len(c.fuzzer.lines) == 0 {
+		return
+	}
+	batch.fuzzed = c.fuzzer.lines
+	c.fuzzer.lines = map[string]int{}
+}
+
+// reportFuzzedLines reports the number of fuzzed lines per case of a pushed
+// batch, tagged by encoding and whether the push request was successful. Loki
+// accepts or rejects the batch as a whole, so the lines of a failed batch were
+// not necessarily the cause of the failure.
+func (c *Client) reportFuzzedLines(batch *Batch, encoding string, success bool) {
+	if len(batch.fuzzed) == 0 {
+		return
+	}
+	now := time.Now()
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+	tags := ctm.Tags.With("encoding", encoding).With("success", strconv.FormatBool(success))
+
+	samples := make([]metrics.Sample, 0, len(batch.fuzzed))
+	for fuzzCase, lines := range batch.fuzzed {
+		// the JSON encoder replaces invalid UTF-8 with the Unicode
+		// replacement character, so Loki never receives it
+		if fuzzCase == "invalid_utf8" && encoding == EncodingJSON {
+			fuzzCase = "invalid_utf8_replaced"
+		}
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientFuzzedLines,
+				Tags:   tags.With("case", fuzzCase),
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(lines),
+			Time:     now,
+		})
+	}
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.ConnectedSamples{Samples: samples})
+}
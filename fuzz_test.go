@@ -0,0 +1,116 @@
+package loki
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+func TestFuzz(t *testing.T) {
+	faker := gofakeit.New(12345)
+	f := flog.New(faker.Rand, faker)
+	line := "level=info msg=\"hello world\""
+	for _, fuzzCase := range flog.FuzzCases {
+		t.Run(fuzzCase, func(t *testing.T) {
+			if fuzzed := f.Fuzz(line, fuzzCase); fuzzed == line {
+				t.Fatalf("expected line to be modified, got %q", fuzzed)
+			}
+		})
+	}
+}
+
+func TestNewFuzzer(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fuzz Fuzz
+		err  bool
+	}{
+		{name: "defaults", fuzz: Fuzz{Ratio: 0.1}},
+		{name: "cases", fuzz: Fuzz{Ratio: 1, Cases: []string{"emoji", "nul"}}},
+		{name: "invalid ratio", fuzz: Fuzz{Ratio: 2}, err: true},
+		{name: "unknown case", fuzz: Fuzz{Ratio: 1, Cases: []string{"zalgo"}}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fuzzer, err := newFuzzer(tc.fuzz)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fuzzer.Cases) == 0 {
+				t.Fatal("expected cases to be set")
+			}
+		})
+	}
+}
+
+func TestFuzzedLines(t *testing.T) {
+	faker := gofakeit.New(12345)
+	fuzzer, err := newFuzzer(Fuzz{Ratio: 0.5, Cases: []string{"ansi", "nul"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker), fuzzer: fuzzer}
+
+	fuzzed := 0
+	for i := 0; i < 1000; i++ {
+		line := c.logEntry(nil, "logfmt", time.Now()).Line
+		if strings.ContainsAny(line, "\x00\x1b") {
+			fuzzed++
+		}
+	}
+	batch := &Batch{}
+	c.collectFuzzedLines(batch)
+	if total := batch.fuzzed["ansi"] + batch.fuzzed["nul"]; total != fuzzed {
+		t.Fatalf("expected %d fuzzed lines, got %d", fuzzed, total)
+	}
+	if fuzzed < 400 || fuzzed > 600 {
+		t.Fatalf("expected around 500 fuzzed lines, got %d", fuzzed)
+	}
+	if len(c.fuzzer.lines) != 0 {
+		t.Fatal("expected fuzzed lines to be reset")
+	}
+}
+
+func TestReportFuzzedLinesJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	for encoding, ratio := range map[string]float64{EncodingJSON: 0, EncodingProtobuf: 1} {
+		c, collect := newPushTestClient(t, srv.URL, func(config *Config) {
+			config.ProtobufRatio = ratio
+			config.Fuzz = &Fuzz{Ratio: 1, Cases: []string{"invalid_utf8"}}
+		})
+		if _, err := c.PushParameterized(1, 1000, 1000); err != nil {
+			t.Fatal(err)
+		}
+		expected := "invalid_utf8"
+		if encoding == EncodingJSON {
+			expected = "invalid_utf8_replaced"
+		}
+		lines := 0.0
+		for _, s := range collect() {
+			if s.Metric.Name != "loki_client_batch_fuzzed_lines" {
+				continue
+			}
+			if fuzzCase, _ := s.Tags.Get("case"); fuzzCase != expected {
+				t.Errorf("expected case %s with %s encoding, got %s", expected, encoding, fuzzCase)
+			}
+			lines += s.Value
+		}
+		if lines == 0 {
+			t.Errorf("expected fuzzed lines with %s encoding", encoding)
+		}
+	}
+}
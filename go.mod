@@ -13,6 +13,7 @@ require (
 	github.com/prometheus/common v0.67.5
 	github.com/sirupsen/logrus v1.9.4
 	go.k6.io/k6 v0.51.1-0.20240610082146-1f01a9bc2365
+	gopkg.in/guregu/null.v3 v3.5.0
 )
 
 require (
@@ -65,7 +66,6 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20260406210006-6f92a3bedf2d // indirect
 	google.golang.org/grpc v1.80.0 // indirect
 	google.golang.org/protobuf v1.36.11 // indirect
-	gopkg.in/guregu/null.v3 v3.5.0 // indirect
 )
 
 // Use fork of gocql that has gokit logs and Prometheus metrics.
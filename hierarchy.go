@@ -0,0 +1,221 @@
+package loki
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+	"github.com/prometheus/common/model"
+)
+
+// HierarchyLevel defines a level of hierarchical labels, e.g.
+// cluster → namespace → app → pod. Each value of a level has its own set of
+// values of the next level, so that only realistic combinations of label
+// values are generated.
+type HierarchyLevel struct {
+	// Name is the label name of the level
+	Name string
+	// Cardinality is the number of values per value of the parent level
+	Cardinality int
+	// Values are explicit values that are used for each value of the
+	// parent level, instead of generated ones
+	Values []string
+}
+
+// labelHierarchy holds all possible combinations of values of hierarchical
+// labels. Each value of a level has the same number of values of the next
+// level, so the leaves of a parent are adjacent.
+type labelHierarchy struct {
+	names  []model.LabelName
+	sizes  []int
+	leaves [][]model.LabelValue
+
+	// samplers select the value of each level, if any level has a
+	// distribution. Otherwise leaves are selected uniformly.
+	samplers []sampler
+
+	// churn rotates the values of the last level, which are generated by
+	// generate
+	churn    *labelChurn
+	generate valueGenerator
+}
+
+// newLabelHierarchy generates the values of all levels. The values of a level
+// are generated with the generator of its label, which may reference the
+// values of the parent levels in templates, e.g. `{{.app}}-{{hex 5}}`.
+func newLabelHierarchy(faker *fake.Faker, levels []HierarchyLevel, generators map[string]string) (*labelHierarchy, error) {
+	h := &labelHierarchy{
+		names:  make([]model.LabelName, 0, len(levels)),
+		sizes:  make([]int, 0, len(levels)),
+		leaves: [][]model.LabelValue{{}},
+	}
+	for _, level := range levels {
+		name := model.LabelName(level.Name)
+		if !name.IsValidLegacy() {
+			return nil, fmt.Errorf("invalid label name %q", level.Name)
+		}
+		for _, n := range h.names {
+			if n == name {
+				return nil, fmt.Errorf("label %q is defined more than once", name)
+			}
+		}
+		if len(level.Values) == 0 && level.Cardinality <= 0 {
+			return nil, fmt.Errorf("label %q requires either values or a cardinality greater than 0", name)
+		}
+		if name == "format" && len(level.Values) == 0 {
+			return nil, fmt.Errorf("the values of label %q need to be defined explicitly", name)
+		}
+
+		generate, err := labelGenerator(faker, level.Name, generators, level.Name+"-%d")
+		if err != nil {
+			return nil, err
+		}
+
+		size := len(level.Values)
+		if size == 0 {
+			size = level.Cardinality
+		}
+		leaves := make([][]model.LabelValue, 0, len(h.leaves)*size)
+		for _, parent := range h.leaves {
+			values := level.Values
+			if len(values) == 0 {
+				values = generateValues(generate.withData(h.data(parent)), level.Cardinality)
+			}
+			for _, v := range values {
+				leaf := make([]model.LabelValue, len(parent), len(parent)+1)
+				copy(leaf, parent)
+				leaves = append(leaves, append(leaf, model.LabelValue(v)))
+			}
+		}
+		h.names = append(h.names, name)
+		h.sizes = append(h.sizes, size)
+		h.leaves = leaves
+	}
+	return h, nil
+}
+
+// has returns whether the label is part of the hierarchy
+func (h *labelHierarchy) has(name model.LabelName) bool {
+	for _, n := range h.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// data returns the values of the labels of a leaf, which are passed to the
+// generators of the next level
+func (h *labelHierarchy) data(leaf []model.LabelValue) map[string]string {
+	data := make(map[string]string, len(leaf))
+	for i, v := range leaf {
+		data[string(h.names[i])] = string(v)
+	}
+	return data
+}
+
+// leafKey returns a unique key of the label values of a leaf
+func leafKey(leaf []model.LabelValue) string {
+	var sb strings.Builder
+	for _, v := range leaf {
+		sb.WriteString(string(v))
+		sb.WriteByte(0xff)
+	}
+	return sb.String()
+}
+
+// hasLeaf returns a function that returns whether the hierarchical labels of a
+// label set are one of the current leaves of the hierarchy. Label sets without
+// hierarchical labels are always accepted.
+func (h *labelHierarchy) hasLeaf() func(model.LabelSet) bool {
+	keys := make(map[string]struct{}, len(h.leaves))
+	for _, leaf := range h.leaves {
+		keys[leafKey(leaf)] = struct{}{}
+	}
+	return func(ls model.LabelSet) bool {
+		leaf := make([]model.LabelValue, len(h.names))
+		for i, name := range h.names {
+			v, ok := ls[name]
+			if !ok {
+				return true
+			}
+			leaf[i] = v
+		}
+		return contains(keys, leafKey(leaf))
+	}
+}
+
+// setDistribution sets the distribution of the values of a level among the
+// values with the same parent
+func (h *labelHierarchy) setDistribution(rand *rand.Rand, name model.LabelName, d Distribution) error {
+	if h.samplers == nil {
+		h.samplers = make([]sampler, len(h.names))
+		for i, size := range h.sizes {
+			h.samplers[i] = &uniformSampler{rand: rand, n: size}
+		}
+	}
+	for i, n := range h.names {
+		if n != name {
+			continue
+		}
+		s, err := newSampler(rand, d, h.sizes[i])
+		if err != nil {
+			return err
+		}
+		h.samplers[i] = s
+	}
+	return nil
+}
+
+// matching returns the leaves with the values of the hierarchical labels of the
+// label set
+func (h *labelHierarchy) matching(ls model.LabelSet) [][]model.LabelValue {
+	var result [][]model.LabelValue
+	for _, leaf := range h.leaves {
+		match := true
+		for i, name := range h.names {
+			if v, ok := ls[name]; ok && v != leaf[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			result = append(result, leaf)
+		}
+	}
+	return result
+}
+
+// sample adds the label values of a random leaf of the hierarchy to the label
+// set
+func (h *labelHierarchy) sample(rand *rand.Rand, ls model.LabelSet) {
+	i := 0
+	if h.samplers == nil {
+		i = rand.Intn(len(h.leaves))
+	} else {
+		// the leaves of a parent are adjacent, so the index of the leaf
+		// is built from the index of the value of each level
+		for level, s := range h.samplers {
+			i = i*h.sizes[level] + s.sample()
+		}
+	}
+	leaf := h.leaves[i]
+	for i, name := range h.names {
+		ls[name] = leaf[i]
+	}
+}
+
+// withoutHierarchy removes the labels that are defined by the hierarchy
+func withoutHierarchy(labels []labelValues, h *labelHierarchy) []labelValues {
+	if h == nil {
+		return labels
+	}
+	result := make([]labelValues, 0, len(labels))
+	for _, l := range labels {
+		if !h.has(l.name) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
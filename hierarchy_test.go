@@ -0,0 +1,67 @@
+package loki
+
+import (
+	"strings"
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+)
+
+func TestNewLabelHierarchy(t *testing.T) {
+	faker := gofakeit.New(12345)
+	h, err := newLabelHierarchy(faker, []HierarchyLevel{
+		{Name: "cluster", Values: []string{"prod-eu", "prod-us"}},
+		{Name: "namespace", Cardinality: 3},
+		{Name: "app", Cardinality: 2},
+		{Name: "pod", Cardinality: 4},
+	}, map[string]string{
+		"namespace": "{{.cluster}}-ns-{{hex 4}}",
+		"app":       "app-%d",
+		"pod":       "{{.app}}-{{hex 5}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := 2 * 3 * 2 * 4; len(h.leaves) != expected {
+		t.Fatalf("expected %d leaves, got %d", expected, len(h.leaves))
+	}
+
+	pods := map[string]struct{}{}
+	for _, leaf := range h.leaves {
+		if len(leaf) != 4 {
+			t.Fatalf("expected 4 label values per leaf, got %v", leaf)
+		}
+		if !strings.HasPrefix(string(leaf[1]), string(leaf[0])+"-ns-") {
+			t.Errorf("expected namespace %s to be prefixed with cluster %s", leaf[1], leaf[0])
+		}
+		if !strings.HasPrefix(string(leaf[3]), string(leaf[2])+"-") {
+			t.Errorf("expected pod %s to be prefixed with app %s", leaf[3], leaf[2])
+		}
+		pods[string(leaf[0]+leaf[1]+leaf[2]+leaf[3])] = struct{}{}
+	}
+	if len(pods) != len(h.leaves) {
+		t.Errorf("expected %d distinct leaves, got %d", len(h.leaves), len(pods))
+	}
+
+	labels := withoutHierarchy(transformLabelPool(LabelPool{"format": {"json"}, "app": {"foo"}}), h)
+	if len(labels) != 1 || labels[0].name != "format" {
+		t.Errorf("expected hierarchical labels to be removed, got %v", labels)
+	}
+}
+
+func TestNewLabelHierarchyErrors(t *testing.T) {
+	faker := gofakeit.New(12345)
+	for name, levels := range map[string][]HierarchyLevel{
+		"invalid name":      {{Name: "foo-bar", Cardinality: 1}},
+		"duplicate name":    {{Name: "app", Cardinality: 1}, {Name: "app", Cardinality: 2}},
+		"no cardinality":    {{Name: "app"}},
+		"generated formats": {{Name: "format", Cardinality: 2}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newLabelHierarchy(faker, levels, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package loki
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/sobek"
+	json "github.com/mailru/easyjson"
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// JSBatch is a batch that is exposed to the Javascript runtime via
+// `client.newBatch()`, so it can be inspected and pushed, possibly more than
+// once, via `client.pushBatch()`. Since `JSON.stringify()` calls `toJSON()`,
+// a batch that is returned by `setup()` is passed to the VUs as the JSON
+// payload of its push request, which `client.pushBatch()` accepts as well.
+type JSBatch struct {
+	// Streams are the streams of the batch
+	Streams []JSBatchStream `js:"streams"`
+	// Lines is the number of lines of the batch
+	Lines int `js:"lines"`
+	// Bytes is the quantity of uncompressed log data of the batch
+	Bytes int `js:"bytes"`
+
+	batch *Batch
+	rt    *sobek.Runtime
+}
+
+// JSBatchStream is a stream of a JSBatch
+type JSBatchStream struct {
+	Labels map[string]string `js:"labels"`
+	Lines  int               `js:"lines"`
+	Bytes  int               `js:"bytes"`
+}
+
+func newJSBatch(rt *sobek.Runtime, batch *Batch) *JSBatch {
+	b := &JSBatch{
+		Streams: make([]JSBatchStream, 0, len(batch.Streams)),
+		Lines:   batch.lines(),
+		Bytes:   batch.Bytes,
+		batch:   batch,
+		rt:      rt,
+	}
+	for key, stream := range batch.Streams {
+		s := JSBatchStream{
+			Labels: labelSetToMap(batch.labels[key]),
+			Lines:  len(stream.Entries),
+		}
+		for _, entry := range stream.Entries {
+			s.Bytes += len(entry.Line)
+		}
+		b.Streams = append(b.Streams, s)
+	}
+	return b
+}
+
+// ToJSON returns the JSON payload of a push request of the batch
+func (b *JSBatch) ToJSON() (string, error) {
+	buf, _, err := b.batch.encodeJSON()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return string(buf), nil
+}
+
+// ToProtobuf returns the snappy compressed protobuf payload of a push request
+// of the batch
+func (b *JSBatch) ToProtobuf() (sobek.ArrayBuffer, error) {
+	buf, _, _, err := b.batch.encodeSnappy()
+	if err != nil {
+		return sobek.ArrayBuffer{}, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return b.rt.NewArrayBuffer(buf), nil
+}
+
+// NewBatch generates a batch like `pushParameterized()` does, without pushing
+// it
+func (c *Client) NewBatch(streams, minBatchSize, maxBatchSize int) (*JSBatch, error) {
+	if minBatchSize > maxBatchSize {
+		return nil, errors.New("minimum batch size needs to be smaller or equal to max batch size")
+	}
+	if state := c.vu.State(); state == nil {
+		return nil, errors.New("state is nil")
+	}
+	batch, err := c.newBatch(streams, minBatchSize, maxBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	return newJSBatch(c.vu.Runtime(), batch), nil
+}
+
+// PushBatch pushes a batch that was created with `client.newBatch()`, or the
+// JSON payload of such a batch, e.g. from the data returned by `setup()`
+func (c *Client) PushBatch(v sobek.Value) (httpext.Response, error) {
+	var batch *Batch
+	switch b := v.Export().(type) {
+	case *JSBatch:
+		batch = b.batch
+	case string:
+		// the batches are kept by the hash of the payload, so that
+		// batches that are pushed more than once are only parsed and
+		// accounted once
+		h := hashLabels(b)
+		if batch = c.jsonBatches[h]; batch == nil {
+			var err error
+			if batch, err = batchFromJSON(b); err != nil {
+				return *httpext.NewResponse(), err
+			}
+			c.findNeedles(batch)
+			if c.jsonBatches == nil {
+				c.jsonBatches = make(map[uint64]*Batch)
+			}
+			c.jsonBatches[h] = batch
+		}
+	}
+	if batch == nil {
+		return *httpext.NewResponse(), errors.New("batch needs to be created with client.newBatch()")
+	}
+	return c.pushBatch(batch)
+}
+
+// batchFromJSON creates a batch from the JSON payload of a push request
+func batchFromJSON(payload string) (*Batch, error) {
+	var req JSONPushRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return nil, fmt.Errorf("invalid batch payload: %w", err)
+	}
+	batch := &Batch{
+		Streams:   make(map[string]*push.Stream, len(req.Streams)),
+		CreatedAt: time.Now(),
+	}
+	for _, s := range req.Streams {
+		labels := make(model.LabelSet, len(s.Stream))
+		for name, value := range s.Stream {
+			labels[model.LabelName(name)] = model.LabelValue(value)
+		}
+		if err := labels.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid labels of batch payload: %w", err)
+		}
+		stream := batch.stream(labels)
+		for _, value := range s.Values {
+			ts, err := strconv.ParseInt(value.Timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp of batch payload: %w", err)
+			}
+			stream.Entries = append(stream.Entries, push.Entry{
+				Timestamp:          time.Unix(0, ts),
+				Line:               value.Line,
+				StructuredMetadata: mapToStructuredMetadata(value.StructuredMetadata),
+			})
+			batch.Bytes += len(value.Line)
+		}
+	}
+	return batch, nil
+}
+
+// mapToStructuredMetadata converts the structured metadata of the JSON payload
+// of push requests to the structured metadata of an entry
+func mapToStructuredMetadata(metadata map[string]string) push.LabelsAdapter {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(push.LabelsAdapter, 0, len(metadata))
+	for name, value := range metadata {
+		result = append(result, push.LabelAdapter{Name: name, Value: value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,152 @@
+package loki
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	json "github.com/mailru/easyjson"
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/js/modulestest"
+)
+
+func TestJSBatch(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	batch, err := c.newStreamsBatch([]PushStream{
+		{Labels: model.LabelSet{"app": "canary", "team": `a, "b"`}, Lines: []string{"canary 1", "canary 2"}},
+		{Labels: model.LabelSet{"app": "api", "format": "logfmt"}, Count: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newJSBatch(nil, batch)
+	if b.Lines != 12 {
+		t.Fatalf("expected 12 lines, got %d", b.Lines)
+	}
+	if len(b.Streams) != 2 {
+		t.Fatalf("expected 2 streams, got %d", len(b.Streams))
+	}
+	bytes := 0
+	for _, s := range b.Streams {
+		if s.Labels["app"] == "canary" && (s.Lines != 2 || s.Bytes != 16 || s.Labels["team"] != `a, "b"`) {
+			t.Fatalf("expected 2 canary lines with 16 bytes, got %d lines with %d bytes and labels %v", s.Lines, s.Bytes, s.Labels)
+		}
+		bytes += s.Bytes
+	}
+	if b.Bytes != bytes {
+		t.Fatalf("expected %d bytes, got %d", bytes, b.Bytes)
+	}
+
+	payload, err := b.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req JSONPushRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for _, s := range req.Streams {
+		lines += len(s.Values)
+	}
+	if lines != 12 {
+		t.Fatalf("expected 12 lines in JSON payload, got %d", lines)
+	}
+}
+
+func TestJSBatchRuntime(t *testing.T) {
+	var lines int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		buf, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Error(err)
+		}
+		var req push.PushRequest
+		if err := req.Unmarshal(buf); err != nil {
+			t.Error(err)
+		}
+		for _, s := range req.Streams {
+			lines += len(s.Entries)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	root := &LokiRoot{}
+	// newVU returns a runtime with a client in the init context, which is
+	// then moved to the VU context
+	newVU := func(vuID uint64) *modulestest.Runtime {
+		rt := modulestest.NewRuntime(t)
+		mi := root.NewModuleInstance(rt.VU)
+		if err := rt.VU.Runtime().Set("loki", mi.Exports().Named); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rt.VU.Runtime().RunString(fmt.Sprintf(`
+			const client = new loki.Client(new loki.Config({ url: %q, protobufRatio: 1, cardinalities: { "app": 2 } }));
+		`, srv.URL)); err != nil {
+			t.Fatal(err)
+		}
+		state, _ := newTestState(rt.VU.InitEnvField.Registry, vuID)
+		rt.MoveToVUContext(state)
+		return rt
+	}
+
+	// the batch is generated in setup and passed to the VU as JSON
+	setup := newVU(0)
+	data, err := setup.VU.Runtime().RunString(`
+		const batch = client.newBatch(2, 10000, 10000);
+		if (typeof batch.toJSON() !== "string" || JSON.parse(batch.toJSON()).streams.length !== 2) {
+			throw new Error("unexpected JSON payload " + batch.toJSON());
+		}
+		if (new Uint8Array(batch.toProtobuf()).length === 0) {
+			throw new Error("empty protobuf payload");
+		}
+		JSON.stringify({ batch: batch, lines: batch.lines });
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vu := newVU(1)
+	if err := vu.VU.Runtime().Set("data", data.String()); err != nil {
+		t.Fatal(err)
+	}
+	res, err := vu.VU.Runtime().RunString(`
+		const setupData = JSON.parse(data);
+		for (let i = 0; i < 2; i++) {
+			const res = client.pushBatch(setupData.batch);
+			if (res.status !== 204) {
+				throw new Error("unexpected status " + res.status);
+			}
+		}
+		let rejected = false;
+		try {
+			client.pushBatch({ streams: [] });
+		} catch (e) {
+			rejected = true;
+		}
+		if (!rejected) {
+			throw new Error("expected objects that are not batches to be rejected");
+		}
+		[setupData.lines, client.stats().lines];
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []int64
+	if err := vu.VU.Runtime().ExportTo(res, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result[0] == 0 || result[1] != result[0] || int64(lines) != 2*result[0] {
+		t.Fatalf("expected %d lines of the setup batch to be pushed twice and accounted once, got %d accounted and %d received", result[0], result[1], lines)
+	}
+}
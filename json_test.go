@@ -0,0 +1,67 @@
+package loki
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+// jsonDepth returns the maximum depth of nested objects of v
+func jsonDepth(v interface{}) int {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	depth := 0
+	for _, child := range obj {
+		depth = max(depth, jsonDepth(child))
+	}
+	return depth + 1
+}
+
+func TestNestedJSON(t *testing.T) {
+	faker := gofakeit.New(12345)
+	opts := flog.JSONOptions{Depth: 3, Keys: 30, KeyCardinality: 50, ArrayRatio: 0.2, ObjectRatio: 0.5}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	f := flog.New(faker.Rand, faker, flog.WithJSONOptions(opts))
+
+	keys := map[string]struct{}{}
+	maxDepth := 0
+	for i := 0; i < 100; i++ {
+		line := f.LogLine("json_nested", time.Now())
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("expected valid JSON, got %s: %v", line, err)
+		}
+		if len(obj) != opts.Keys+3 {
+			t.Fatalf("expected %d keys, got %d", opts.Keys+3, len(obj))
+		}
+		for k := range obj {
+			keys[k] = struct{}{}
+		}
+		maxDepth = max(maxDepth, jsonDepth(obj))
+	}
+	if maxDepth != opts.Depth {
+		t.Errorf("expected depth %d, got %d", opts.Depth, maxDepth)
+	}
+	if len(keys) > opts.KeyCardinality+3 {
+		t.Errorf("expected at most %d distinct keys, got %d", opts.KeyCardinality+3, len(keys))
+	}
+}
+
+func TestJSONOptionsValidate(t *testing.T) {
+	for _, opts := range []flog.JSONOptions{
+		{Depth: 0, Keys: 1, KeyCardinality: 1},
+		{Depth: 1, Keys: 1, KeyCardinality: 0},
+		{Depth: 1, Keys: 1, KeyCardinality: 1, ArrayRatio: 0.6, ObjectRatio: 0.6},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
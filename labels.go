@@ -0,0 +1,423 @@
+package loki
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+	"github.com/prometheus/common/model"
+)
+
+type FakeFunc func() string
+
+type LabelPool map[model.LabelName][]string
+
+type labelValues struct {
+	name    model.LabelName
+	values  []string
+	sampler sampler
+	churn   *labelChurn
+}
+
+// defaultGenerators are the gofakeit functions used for the built-in label
+// names, if no generator is configured.
+var defaultGenerators = map[string]string{
+	"namespace": "BS",
+	"app":       "AppName",
+	"pod":       "BS",
+	"language":  "LanguageAbbreviation",
+	"word":      "Noun",
+}
+
+// maxGenerateAttempts is the maximum number of attempts per value to generate
+// distinct label values before values are made unique by adding a suffix.
+const maxGenerateAttempts = 10
+
+// maxSampleAttempts is the number of attempts per label set to randomly
+// sample distinct label sets, before the remaining label sets are selected from
+// all possible label sets
+const maxSampleAttempts = 10
+
+// maxEnumeratedLabelSets is the maximum number of possible label sets that are
+// enumerated when distinct label sets cannot be sampled randomly
+const maxEnumeratedLabelSets = 100000
+
+// getRandomLabelSet creates a label set from the possible Client labels
+func (c *Client) getRandomLabelSet() model.LabelSet {
+	ls := make(model.LabelSet, len(c.labels))
+	for _, label := range c.labels {
+		ls[label.name] = model.LabelValue(label.values[label.sample(c.rand)])
+	}
+	if c.hierarchy != nil {
+		c.hierarchy.sample(c.rand, ls)
+	}
+	return ls
+}
+
+// labelSetCount returns the number of possible label sets, capped at
+// math.MaxInt
+func (c *Client) labelSetCount() int {
+	count := 1
+	if c.hierarchy != nil {
+		count = len(c.hierarchy.leaves)
+	}
+	for _, label := range c.labels {
+		n := len(label.values)
+		if n > 0 && count > math.MaxInt/n {
+			return math.MaxInt
+		}
+		count *= n
+	}
+	return count
+}
+
+// getDistinctLabelSets returns n distinct random label sets. The label sets
+// are sampled randomly, following the distributions of the labels, without
+// replacement. If not enough distinct label sets are sampled, for example
+// because the number of possible label sets is close to n, the remaining label
+// sets are chosen randomly from all possible label sets.
+func (c *Client) getDistinctLabelSets(n int) ([]model.LabelSet, error) {
+	if count := c.labelSetCount(); n > count {
+		return nil, fmt.Errorf("cannot create %d distinct streams, there are only %d possible label sets", n, count)
+	}
+
+	result := make([]model.LabelSet, 0, n)
+	seen := make(map[string]struct{}, n)
+	for attempts := 0; len(result) < n && attempts < n*maxSampleAttempts; attempts++ {
+		ls := c.getRandomLabelSet()
+		if key := ls.String(); !contains(seen, key) {
+			seen[key] = struct{}{}
+			result = append(result, ls)
+		}
+	}
+	if len(result) == n {
+		return result, nil
+	}
+
+	if count := c.labelSetCount(); count > maxEnumeratedLabelSets {
+		return nil, fmt.Errorf("could not sample %d distinct streams from %d possible label sets, check the distributions of the labels", n, count)
+	}
+	all := c.allLabelSets()
+	c.rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+	for _, ls := range all {
+		if len(result) == n {
+			break
+		}
+		if key := ls.String(); !contains(seen, key) {
+			seen[key] = struct{}{}
+			result = append(result, ls)
+		}
+	}
+	return result, nil
+}
+
+// allLabelSets returns all possible label sets
+func (c *Client) allLabelSets() []model.LabelSet {
+	result := []model.LabelSet{{}}
+	if c.hierarchy != nil {
+		result = make([]model.LabelSet, 0, len(c.hierarchy.leaves))
+		for _, leaf := range c.hierarchy.leaves {
+			ls := make(model.LabelSet, len(c.hierarchy.names)+len(c.labels))
+			for i, name := range c.hierarchy.names {
+				ls[name] = leaf[i]
+			}
+			result = append(result, ls)
+		}
+	}
+	for _, label := range c.labels {
+		next := make([]model.LabelSet, 0, len(result)*len(label.values))
+		for _, ls := range result {
+			for _, v := range label.values {
+				cloned := ls.Clone()
+				cloned[label.name] = model.LabelValue(v)
+				next = append(next, cloned)
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// sample returns the index of a random value of the label, using the
+// label's distribution if one is set
+func (l *labelValues) sample(rand *rand.Rand) int {
+	if l.sampler == nil {
+		return rand.Intn(len(l.values))
+	}
+	return l.sampler.sample()
+}
+
+// applyDistributions sets the samplers of the labels that have a distribution.
+// The distribution of a hierarchical label applies to the values of each
+// parent.
+func applyDistributions(rand *rand.Rand, labels []labelValues, h *labelHierarchy, distributions map[string]Distribution) error {
+	for name, d := range distributions {
+		if h != nil && h.has(model.LabelName(name)) {
+			if err := h.setDistribution(rand, model.LabelName(name), d); err != nil {
+				return fmt.Errorf("invalid distribution for label %q: %w", name, err)
+			}
+			continue
+		}
+		found := false
+		for i := range labels {
+			if string(labels[i].name) != name {
+				continue
+			}
+			s, err := newSampler(rand, d, len(labels[i].values))
+			if err != nil {
+				return fmt.Errorf("invalid distribution for label %q: %w", name, err)
+			}
+			labels[i].sampler = s
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("distribution defined for unknown label %q", name)
+		}
+	}
+	return nil
+}
+
+// valueGenerator generates a label value. The data contains the values of the
+// parent labels of hierarchical labels, which can be referenced in templates,
+// e.g. `{{.app}}-{{hex 5}}`.
+type valueGenerator func(data map[string]string) string
+
+// withData returns a FakeFunc that generates values with the given data
+func (g valueGenerator) withData(data map[string]string) FakeFunc {
+	return func() string {
+		return g(data)
+	}
+}
+
+// newLabelGenerator creates a valueGenerator from a generator definition,
+// which is one of
+//   - a format string with a `%d` verb, e.g. `value-%d`, which generates
+//     sequential values
+//   - a template, e.g. `pod-{{hex 5}}`, see labelTemplateFuncs
+//   - the name of a gofakeit function, e.g. `AppName`
+func newLabelGenerator(faker *fake.Faker, generator string) (valueGenerator, error) {
+	switch {
+	case strings.Contains(generator, "{{"):
+		tmpl, err := template.New("").Funcs(labelTemplateFuncs(faker)).Parse(generator)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", generator, err)
+		}
+		// execute the template once, so errors of the template
+		// functions surface when the generator is created
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, map[string]string{}); err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", generator, err)
+		}
+		return func(data map[string]string) string {
+			buf.Reset()
+			_ = tmpl.Execute(&buf, data)
+			return buf.String()
+		}, nil
+	case strings.Contains(generator, "%d"):
+		i := 0
+		return func(map[string]string) string {
+			v := fmt.Sprintf(generator, i)
+			i++
+			return v
+		}, nil
+	default:
+		ff, err := fakeFunc(faker, generator)
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]string) string {
+			return ff()
+		}, nil
+	}
+}
+
+// labelTemplateFuncs returns the functions that can be used in label value
+// templates
+func labelTemplateFuncs(faker *fake.Faker) template.FuncMap {
+	return template.FuncMap{
+		// hex returns n random hexadecimal characters
+		"hex": func(n int) string {
+			const chars = "0123456789abcdef"
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = chars[faker.Rand.Intn(len(chars))]
+			}
+			return string(b)
+		},
+		// number returns a random number between min and max
+		"number": faker.Number,
+		// fake calls the gofakeit function with the given name
+		"fake": func(name string) (string, error) {
+			ff, err := fakeFunc(faker, name)
+			if err != nil {
+				return "", err
+			}
+			return ff(), nil
+		},
+	}
+}
+
+// fakeFunc looks up the gofakeit function with the given name, ignoring its
+// case. Only functions without arguments that return a string can be used.
+func fakeFunc(faker *fake.Faker, name string) (FakeFunc, error) {
+	v := reflect.ValueOf(faker)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !strings.EqualFold(m.Name, name) {
+			continue
+		}
+		if ff, ok := v.Method(i).Interface().(func() string); ok {
+			return ff, nil
+		}
+		return nil, fmt.Errorf("gofakeit function %q needs to return a string and must not take arguments", m.Name)
+	}
+	return nil, fmt.Errorf("unknown gofakeit function %q", name)
+}
+
+// defaultFormats are the values of the built-in `format` label. All of them
+// need to be valid flog formats, see LabelValuesFormat. The formats that were
+// added later are opt-in via custom labels, so that the streams and lines of
+// existing configurations do not change.
+var defaultFormats = []string{"apache_common", "apache_combined", "apache_error", "rfc3164", "rfc5424", "json", "logfmt"}
+
+// legacyLabels are the built-in labels that were supported before label
+// generators were configurable, in the order in which their values are
+// generated.
+var legacyLabels = []string{"namespace", "app", "pod", "language", "word"}
+
+// generateLegacyValues returns `n` label values generated with the `ff`
+// function, which are not necessarily distinct.
+func generateLegacyValues(ff FakeFunc, n int) []string {
+	res := make([]string, n)
+	for i := 0; i < n; i++ {
+		res[i] = ff()
+	}
+	return res
+}
+
+// generateValues returns `n` distinct label values generated with the `ff`
+// function. If the function does not produce enough distinct values, the
+// remaining values are made unique by adding a numeric suffix.
+func generateValues(ff FakeFunc, n int) []string {
+	res := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for attempts := 0; len(res) < n && attempts < n*maxGenerateAttempts; attempts++ {
+		v := ff()
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	for i := 0; len(res) < n; i++ {
+		v := fmt.Sprintf("%s-%d", ff(), i)
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	return res
+}
+
+// labelGenerator returns the generator for the given label name, which is
+// either the configured generator, the default generator of a built-in label,
+// or the fallback generator.
+func labelGenerator(faker *fake.Faker, name string, generators map[string]string, fallback string) (valueGenerator, error) {
+	generator, ok := generators[name]
+	if !ok {
+		generator, ok = defaultGenerators[name]
+	}
+	if !ok {
+		generator = fallback
+	}
+	ff, err := newLabelGenerator(faker, generator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generator for label %q: %w", name, err)
+	}
+	return ff, nil
+}
+
+// newLabelPool creates a "pool" of values for each label name. The values of
+// each label are generated with the generator of the label, if configured, or
+// else with the default generator of the built-in label. Labels without a
+// generator get sequential values in format `{label}-{n}`.
+//
+// The values of the built-in labels without configured generator are
+// generated first, in their original order and without deduplication, so they
+// stay the same for a given random seed. The values of all other labels are
+// distinct.
+func newLabelPool(faker *fake.Faker, cardinalities map[string]int, generators map[string]string) (LabelPool, error) {
+	lb := LabelPool{
+		"format": defaultFormats,
+		"os":     []string{"darwin", "linux", "windows"},
+	}
+
+	legacy := make(map[string]struct{}, len(legacyLabels))
+	for _, name := range legacyLabels {
+		n, ok := cardinalities[name]
+		if _, configured := generators[name]; !ok || configured || n <= 0 {
+			continue
+		}
+		ff, err := labelGenerator(faker, name, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		lb[model.LabelName(name)] = generateLegacyValues(ff.withData(nil), n)
+		legacy[name] = struct{}{}
+	}
+
+	// sort the label names so the generated values are deterministic for
+	// a given random seed
+	names := make([]string, 0, len(cardinalities))
+	for name := range cardinalities {
+		if !contains(legacy, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := cardinalities[name]
+		if name == "format" {
+			return nil, fmt.Errorf("the cardinality of label %q cannot be changed, use custom labels instead", name)
+		}
+		if !model.LabelName(name).IsValidLegacy() {
+			return nil, fmt.Errorf("invalid label name %q", name)
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("cardinality of label %q needs to be greater than 0", name)
+		}
+
+		ff, err := labelGenerator(faker, name, generators, name+"-%d")
+		if err != nil {
+			return nil, err
+		}
+		lb[model.LabelName(name)] = generateValues(ff.withData(nil), n)
+	}
+	return lb, nil
+}
+
+func transformLabelPool(pool LabelPool) []labelValues {
+	keys := make([]string, 0, len(pool))
+	for k := range pool {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	result := make([]labelValues, len(pool))
+	for i, k := range keys {
+		ln := model.LabelName(k)
+		result[i] = labelValues{
+			name:   ln,
+			values: pool[ln],
+		}
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package loki
+
+import (
+	"regexp"
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/prometheus/common/model"
+)
+
+func TestNewLabelPool(t *testing.T) {
+	faker := gofakeit.New(12345)
+	pool, err := newLabelPool(faker, map[string]int{
+		"app":         5,
+		"cluster":     3,
+		"tenant_team": 40,
+		"pod":         20,
+		"region":      4,
+	}, map[string]string{
+		"pod":    "pod-{{hex 5}}",
+		"region": "City",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, n := range map[model.LabelName]int{"app": 5, "cluster": 3, "tenant_team": 40, "pod": 20, "region": 4} {
+		values := pool[name]
+		if len(values) != n {
+			t.Fatalf("expected %d values for label %s, got %d", n, name, len(values))
+		}
+		seen := map[string]struct{}{}
+		for _, v := range values {
+			seen[v] = struct{}{}
+		}
+		if len(seen) != n {
+			t.Fatalf("expected %d distinct values for label %s, got %v", n, name, values)
+		}
+	}
+
+	if pool["cluster"][0] != "cluster-0" || pool["cluster"][2] != "cluster-2" {
+		t.Errorf("expected sequential values for cluster, got %v", pool["cluster"])
+	}
+	re := regexp.MustCompile(`^pod-[0-9a-f]{5}$`)
+	for _, v := range pool["pod"] {
+		if !re.MatchString(v) {
+			t.Errorf("expected pod value to match %s, got %s", re, v)
+		}
+	}
+}
+
+func TestNewLabelPoolErrors(t *testing.T) {
+	faker := gofakeit.New(12345)
+	for name, tc := range map[string]struct {
+		cardinalities map[string]int
+		generators    map[string]string
+	}{
+		"invalid label name":   {map[string]int{"foo-bar": 1}, nil},
+		"zero cardinality":     {map[string]int{"app": 0}, nil},
+		"format label":         {map[string]int{"format": 2}, nil},
+		"unknown function":     {map[string]int{"app": 1}, map[string]string{"app": "DoesNotExist"}},
+		"function with args":   {map[string]int{"app": 1}, map[string]string{"app": "Number"}},
+		"invalid template":     {map[string]int{"app": 1}, map[string]string{"app": "{{hex"}},
+		"invalid template arg": {map[string]int{"app": 1}, map[string]string{"app": `{{fake "DoesNotExist"}}`}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newLabelPool(faker, tc.cardinalities, tc.generators); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestNewLabelPoolLegacyValues(t *testing.T) {
+	// the values of the built-in labels are the same as before label
+	// generators were configurable
+	faker := gofakeit.New(12345)
+	expected := map[model.LabelName][]string{
+		"namespace": generateLegacyValues(faker.BS, 10),
+		"app":       generateLegacyValues(faker.AppName, 5),
+		"pod":       generateLegacyValues(faker.BS, 50),
+	}
+
+	pool, err := newLabelPool(gofakeit.New(12345), map[string]int{"app": 5, "namespace": 10, "pod": 50, "cluster": 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range expected {
+		for i := range values {
+			if pool[name][i] != values[i] {
+				t.Fatalf("expected values %v for label %s, got %v", values, name, pool[name])
+			}
+		}
+	}
+}
+
+func TestDefaultFormats(t *testing.T) {
+	for _, f := range defaultFormats {
+		if !isValidLogFormat(f) {
+			t.Errorf("default format %s is not a valid log format", f)
+		}
+	}
+}
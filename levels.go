@@ -0,0 +1,104 @@
+package loki
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/grafana/xk6-loki/flog"
+)
+
+// Levels defines the distribution of the log levels of the generated lines.
+type Levels struct {
+	// Weights are the relative weights of the levels, see flog.Levels.
+	// Levels without weight are not generated.
+	Weights map[string]float64
+	// Incident periodically increases the ratio of error lines
+	Incident *Incident
+}
+
+// Incident defines periods with an increased ratio of error lines. An incident
+// starts every Interval and lasts for Duration. Incidents are aligned to the
+// Unix epoch, so they happen at the same time for all VUs.
+type Incident struct {
+	Interval   time.Duration
+	Duration   time.Duration
+	ErrorRatio float64
+}
+
+// levelSampler samples the levels of log lines
+type levelSampler struct {
+	rand     *rand.Rand
+	levels   []string
+	sampler  sampler
+	incident *Incident
+	// nonError samples the levels other than error during incidents
+	nonError       sampler
+	nonErrorLevels []string
+}
+
+func newLevelSampler(r *rand.Rand, l Levels) (*levelSampler, error) {
+	ls := &levelSampler{rand: r, incident: l.Incident}
+	var weights, nonErrorWeights []float64
+	// iterate over flog.Levels to keep the order deterministic
+	for _, level := range flog.Levels {
+		w, ok := l.Weights[level]
+		if !ok {
+			continue
+		}
+		ls.levels = append(ls.levels, level)
+		weights = append(weights, w)
+		if level != "error" {
+			ls.nonErrorLevels = append(ls.nonErrorLevels, level)
+			nonErrorWeights = append(nonErrorWeights, w)
+		}
+	}
+	for level := range l.Weights {
+		if !slices.Contains(flog.Levels, level) {
+			return nil, fmt.Errorf("unknown level %q, must be one of %v", level, flog.Levels)
+		}
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("weights of at least one level are required")
+	}
+
+	s, err := newSampler(r, Distribution{Type: DistributionWeighted, Weights: weights}, len(weights))
+	if err != nil {
+		return nil, fmt.Errorf("invalid level weights: %w", err)
+	}
+	ls.sampler = s
+
+	if l.Incident != nil {
+		if l.Incident.Interval <= 0 || l.Incident.Duration <= 0 || l.Incident.Duration > l.Incident.Interval {
+			return nil, fmt.Errorf("incident requires 0 < duration <= interval")
+		}
+		if l.Incident.ErrorRatio < 0 || l.Incident.ErrorRatio > 1 {
+			return nil, fmt.Errorf("incident errorRatio needs to be between 0 and 1, got %v", l.Incident.ErrorRatio)
+		}
+		if len(nonErrorWeights) > 0 {
+			s, err := newSampler(r, Distribution{Type: DistributionWeighted, Weights: nonErrorWeights}, len(nonErrorWeights))
+			if err != nil {
+				return nil, fmt.Errorf("invalid level weights: %w", err)
+			}
+			ls.nonError = s
+		}
+	}
+	return ls, nil
+}
+
+// inIncident returns whether an incident is ongoing at the given time
+func (s *levelSampler) inIncident(t time.Time) bool {
+	return s.incident != nil && time.Duration(t.UnixNano()%int64(s.incident.Interval)) < s.incident.Duration
+}
+
+// sample returns the level of a log line generated at the given time
+func (s *levelSampler) sample(t time.Time) string {
+	if s.inIncident(t) {
+		if s.nonError == nil || s.rand.Float64() < s.incident.ErrorRatio {
+			return "error"
+		}
+		return s.nonErrorLevels[s.nonError.sample()]
+	}
+	return s.levels[s.sampler.sample()]
+}
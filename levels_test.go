@@ -0,0 +1,84 @@
+package loki
+
+import (
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+func TestLevelSampler(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s, err := newLevelSampler(r, Levels{
+		Weights:  map[string]float64{"info": 9, "error": 1},
+		Incident: &Incident{Interval: time.Hour, Duration: 10 * time.Minute, ErrorRatio: 0.8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(t time.Time) int {
+		errors := 0
+		for i := 0; i < 1000; i++ {
+			if s.sample(t) == "error" {
+				errors++
+			}
+		}
+		return errors
+	}
+	hour := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if n := count(hour.Add(5 * time.Minute)); n < 700 || n > 900 {
+		t.Errorf("expected about 800 errors during incident, got %d", n)
+	}
+	if n := count(hour.Add(30 * time.Minute)); n < 50 || n > 150 {
+		t.Errorf("expected about 100 errors outside of incident, got %d", n)
+	}
+}
+
+func TestLevelSamplerInvalid(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for name, l := range map[string]Levels{
+		"unknown level":   {Weights: map[string]float64{"verbose": 1}},
+		"no weights":      {},
+		"invalid ratio":   {Weights: map[string]float64{"info": 1}, Incident: &Incident{Interval: time.Hour, Duration: time.Minute, ErrorRatio: 2}},
+		"invalid periods": {Weights: map[string]float64{"info": 1}, Incident: &Incident{Interval: time.Minute, Duration: time.Hour}},
+	} {
+		if _, err := newLevelSampler(r, l); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLevelInLogLines(t *testing.T) {
+	faker := gofakeit.New(12345)
+	f := flog.New(faker.Rand, faker, flog.WithLevel(func(time.Time) string { return "warn" }))
+	now := time.Now()
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(f.LogLine("json", now)), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["level"] != "warn" {
+		t.Errorf("expected level warn, got %v", fields["level"])
+	}
+
+	if line := f.LogLine("logfmt", now); !regexp.MustCompile(`^level=warn `).MatchString(line) {
+		t.Errorf("expected logfmt line with level, got %q", line)
+	}
+
+	for _, format := range []string{"rfc3164", "rfc5424"} {
+		m := regexp.MustCompile(`^<(\d+)>`).FindStringSubmatch(f.LogLine(format, now))
+		if m == nil {
+			t.Fatalf("%s: expected priority", format)
+		}
+		pri, _ := strconv.Atoi(m[1])
+		if pri%8 != 4 {
+			t.Errorf("%s: expected severity warning, got priority %d", format, pri)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package loki
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+const (
+	LineLengthFixed     = "fixed"
+	LineLengthUniform   = "uniform"
+	LineLengthNormal    = "normal"
+	LineLengthHistogram = "histogram"
+)
+
+// DefaultOversizeSize is the size of oversize lines, if not configured. It is
+// one byte larger than the default `max_line_size` of Loki.
+const DefaultOversizeSize = 256*1024 + 1
+
+// LineLength defines the distribution of the sizes of the generated log lines
+// in bytes. Lines are padded or truncated to the sampled size.
+type LineLength struct {
+	// Distribution is one of fixed, uniform, normal or histogram
+	Distribution string
+	// Size is the size of the lines of the fixed distribution
+	Size int
+	// Min and Max are the bounds of the uniform distribution
+	Min int
+	Max int
+	// Mean and StdDev are the parameters of the normal distribution
+	Mean   float64
+	StdDev float64
+	// Buckets are the sizes and relative weights of the histogram
+	// distribution
+	Buckets []LineLengthBucket
+	// OversizeRatio is the ratio of lines that are OversizeSize bytes long,
+	// regardless of the distribution
+	OversizeRatio float64
+	OversizeSize  int
+}
+
+type LineLengthBucket struct {
+	Size   int
+	Weight float64
+}
+
+// lineLength samples the sizes of log lines
+type lineLength struct {
+	LineLength
+	rand    *rand.Rand
+	buckets sampler
+}
+
+func newLineLength(r *rand.Rand, l LineLength) (*lineLength, error) {
+	ll := &lineLength{LineLength: l, rand: r}
+	switch l.Distribution {
+	case LineLengthFixed:
+		if l.Size <= 0 {
+			return nil, fmt.Errorf("fixed line length requires size > 0, got %d", l.Size)
+		}
+	case LineLengthUniform:
+		if l.Min <= 0 || l.Max < l.Min {
+			return nil, fmt.Errorf("uniform line length requires 0 < min <= max, got min=%d max=%d", l.Min, l.Max)
+		}
+	case LineLengthNormal:
+		if l.Mean <= 0 || l.StdDev < 0 {
+			return nil, fmt.Errorf("normal line length requires mean > 0 and stddev >= 0, got mean=%v stddev=%v", l.Mean, l.StdDev)
+		}
+	case LineLengthHistogram:
+		weights := make([]float64, len(l.Buckets))
+		for i, b := range l.Buckets {
+			if b.Size <= 0 {
+				return nil, fmt.Errorf("histogram buckets require size > 0, got %d", b.Size)
+			}
+			weights[i] = b.Weight
+		}
+		if len(weights) == 0 {
+			return nil, fmt.Errorf("histogram line length requires at least one bucket")
+		}
+		s, err := newSampler(r, Distribution{Type: DistributionWeighted, Weights: weights}, len(weights))
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram buckets: %w", err)
+		}
+		ll.buckets = s
+	default:
+		return nil, fmt.Errorf("unknown line length distribution %q", l.Distribution)
+	}
+
+	if l.OversizeRatio < 0 || l.OversizeRatio > 1 {
+		return nil, fmt.Errorf("oversizeRatio needs to be between 0 and 1, got %v", l.OversizeRatio)
+	}
+	if ll.OversizeSize == 0 {
+		ll.OversizeSize = DefaultOversizeSize
+	}
+	if ll.OversizeSize < 0 {
+		return nil, fmt.Errorf("oversizeSize needs to be greater than 0, got %d", l.OversizeSize)
+	}
+	return ll, nil
+}
+
+// sample returns the size of the next line
+func (l *lineLength) sample() int {
+	if l.OversizeRatio > 0 && l.rand.Float64() < l.OversizeRatio {
+		return l.OversizeSize
+	}
+	switch l.Distribution {
+	case LineLengthUniform:
+		return l.Min + l.rand.Intn(l.Max-l.Min+1)
+	case LineLengthNormal:
+		return max(1, int(math.Round(l.Mean+l.StdDev*l.rand.NormFloat64())))
+	case LineLengthHistogram:
+		return l.Buckets[l.buckets.sample()].Size
+	default:
+		return l.Size
+	}
+}
@@ -0,0 +1,79 @@
+package loki
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+func TestNewLineLength(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name  string
+		l     LineLength
+		valid bool
+	}{
+		{"fixed", LineLength{Distribution: LineLengthFixed, Size: 100}, true},
+		{"fixed without size", LineLength{Distribution: LineLengthFixed}, false},
+		{"uniform", LineLength{Distribution: LineLengthUniform, Min: 10, Max: 20}, true},
+		{"uniform min > max", LineLength{Distribution: LineLengthUniform, Min: 20, Max: 10}, false},
+		{"normal", LineLength{Distribution: LineLengthNormal, Mean: 100, StdDev: 10}, true},
+		{"histogram", LineLength{Distribution: LineLengthHistogram, Buckets: []LineLengthBucket{{Size: 10, Weight: 1}}}, true},
+		{"histogram without buckets", LineLength{Distribution: LineLengthHistogram}, false},
+		{"invalid oversize ratio", LineLength{Distribution: LineLengthFixed, Size: 100, OversizeRatio: 2}, false},
+		{"unknown", LineLength{Distribution: "foo"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newLineLength(r, tc.l)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLogLineLength(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	for _, format := range LabelValuesFormat {
+		for _, size := range []int{20, 1000, 100000} {
+			l, err := newLineLength(faker.Rand, LineLength{Distribution: LineLengthFixed, Size: size})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.lineLength = l
+			line := c.logEntry(nil, format, time.Now()).Line
+			if len(line) > size || len(line) < size-3 {
+				t.Errorf("%s: expected line of %d bytes, got %d", format, size, len(line))
+			}
+			if format == "json" && size > 1000 && !json.Valid([]byte(line)) {
+				t.Errorf("expected padded line to be valid JSON: %s", line)
+			}
+		}
+	}
+}
+
+func TestLineLengthOversize(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l, err := newLineLength(r, LineLength{Distribution: LineLengthFixed, Size: 100, OversizeRatio: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oversize := 0
+	for i := 0; i < 1000; i++ {
+		if l.sample() == DefaultOversizeSize {
+			oversize++
+		}
+	}
+	if oversize < 400 || oversize > 600 {
+		t.Fatalf("expected about 500 oversize lines, got %d", oversize)
+	}
+}
@@ -0,0 +1,76 @@
+package loki
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+// parseLogfmt parses a logfmt line into key-value pairs
+func parseLogfmt(t *testing.T, line string) map[string]string {
+	fields := map[string]string{}
+	for line != "" {
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			t.Fatalf("invalid logfmt line %q", line)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				t.Fatalf("invalid quoted value %q: %v", rest, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		fields[key] = value
+		line = strings.TrimPrefix(rest, " ")
+	}
+	return fields
+}
+
+func TestWideLogfmt(t *testing.T) {
+	faker := gofakeit.New(12345)
+	opts := flog.LogfmtOptions{
+		Keys:          10,
+		KeyNames:      []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"},
+		Cardinalities: map[string]int{"request_id": 5, "user_id": 1},
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	f := flog.New(faker.Rand, faker, flog.WithLogfmtOptions(opts))
+
+	requestIDs := map[string]struct{}{}
+	userIDs := map[string]struct{}{}
+	for i := 0; i < 100; i++ {
+		fields := parseLogfmt(t, f.LogLine("logfmt_wide", time.Now()))
+		if len(fields) != opts.Keys+3 {
+			t.Fatalf("expected %d keys, got %d: %v", opts.Keys+3, len(fields), fields)
+		}
+		requestIDs[fields["request_id"]] = struct{}{}
+		userIDs[fields["user_id"]] = struct{}{}
+	}
+	if len(requestIDs) != 5 || len(userIDs) != 1 {
+		t.Errorf("expected 5 request IDs and 1 user ID, got %d and %d", len(requestIDs), len(userIDs))
+	}
+}
+
+func TestLogfmtOptionsValidate(t *testing.T) {
+	for _, opts := range []flog.LogfmtOptions{
+		{Keys: 1, KeyCardinality: 0},
+		{Keys: 1, KeyNames: []string{"a b"}},
+		{Keys: 1, KeyCardinality: 1, Cardinalities: map[string]int{"id": 0}},
+		{Keys: 1, KeyCardinality: 1, Cardinalities: map[string]int{"a": 1, "b": 1}},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
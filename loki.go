@@ -41,6 +41,21 @@ var _ modules.Module = &LokiRoot{}
 type lokiMetrics struct {
 	ClientUncompressedBytes  *metrics.Metric
 	ClientLines              *metrics.Metric
+	ClientRetries            *metrics.Metric
+	ClientDroppedLines       *metrics.Metric
+	ClientDroppedBytes       *metrics.Metric
+	ClientPushRejections     *metrics.Metric
+	ClientShedLines          *metrics.Metric
+	ClientShedBytes          *metrics.Metric
+	ClientEncodedBytes       *metrics.Metric
+	ClientEncodeDuration     *metrics.Metric
+	ClientCompressionRatio   *metrics.Metric
+	ClientBatchStreams       *metrics.Metric
+	ClientLinesPerStream     *metrics.Metric
+	ClientActiveStreams      *metrics.Metric
+	ClientNewStreams         *metrics.Metric
+	ClientLabelBytes         *metrics.Metric
+	ClientFuzzedLines        *metrics.Metric
 	BytesProcessedTotal      *metrics.Metric
 	BytesProcessedPerSeconds *metrics.Metric
 	LinesProcessedTotal      *metrics.Metric
@@ -48,16 +63,19 @@ type lokiMetrics struct {
 }
 
 // LokiRoot is the root module
-type LokiRoot struct{}
+type LokiRoot struct {
+	// pools are the batch pools that are shared by all VUs
+	pools batchPools
+}
 
-func (*LokiRoot) NewModuleInstance(vu modules.VU) modules.Instance {
+func (root *LokiRoot) NewModuleInstance(vu modules.VU) modules.Instance {
 	m, err := registerMetrics(vu)
 	if err != nil {
 		common.Throw(vu.Runtime(), err)
 	}
 
 	logger := vu.InitEnv().Logger.WithField("component", "xk6-loki")
-	return &Loki{vu: vu, metrics: m, logger: logger}
+	return &Loki{vu: vu, metrics: m, logger: logger, pools: &root.pools}
 }
 
 func registerMetrics(vu modules.VU) (lokiMetrics, error) {
@@ -75,6 +93,81 @@ func registerMetrics(vu modules.VU) (lokiMetrics, error) {
 		return m, err
 	}
 
+	m.ClientRetries, err = registry.NewMetric("loki_client_retries", metrics.Counter, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientDroppedLines, err = registry.NewMetric("loki_client_dropped_lines", metrics.Counter, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientDroppedBytes, err = registry.NewMetric("loki_client_dropped_bytes", metrics.Counter, metrics.Data)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientPushRejections, err = registry.NewMetric("loki_client_push_rejections", metrics.Counter, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientShedLines, err = registry.NewMetric("loki_client_shed_lines", metrics.Counter, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientShedBytes, err = registry.NewMetric("loki_client_shed_bytes", metrics.Counter, metrics.Data)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientEncodedBytes, err = registry.NewMetric("loki_client_encoded_bytes", metrics.Trend, metrics.Data)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientEncodeDuration, err = registry.NewMetric("loki_client_encode_duration", metrics.Trend, metrics.Time)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientCompressionRatio, err = registry.NewMetric("loki_client_compression_ratio", metrics.Trend, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientBatchStreams, err = registry.NewMetric("loki_client_batch_streams", metrics.Trend, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientLinesPerStream, err = registry.NewMetric("loki_client_lines_per_stream", metrics.Trend, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientActiveStreams, err = registry.NewMetric("loki_client_active_streams", metrics.Gauge, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientNewStreams, err = registry.NewMetric("loki_client_new_streams", metrics.Counter, metrics.Default)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientLabelBytes, err = registry.NewMetric("loki_client_label_bytes", metrics.Counter, metrics.Data)
+	if err != nil {
+		return m, err
+	}
+
+	m.ClientFuzzedLines, err = registry.NewMetric("loki_client_batch_fuzzed_lines", metrics.Counter)
+	if err != nil {
+		return m, err
+	}
+
 	m.BytesProcessedTotal, err = registry.NewMetric("loki_bytes_processed_total", metrics.Counter, metrics.Data)
 	if err != nil {
 		return m, err
@@ -103,6 +196,7 @@ type Loki struct {
 	vu      modules.VU
 	metrics lokiMetrics
 	logger  logrus.FieldLogger
+	pools   *batchPools
 }
 
 func (r *Loki) Exports() modules.Exports {
@@ -128,14 +222,19 @@ func (r *Loki) config(c sobek.ConstructorCall) *sobek.Object {
 		Timeout:       time.Duration(DefaultPushTimeout) * time.Millisecond,
 		ProtobufRatio: DefaultProtobufRatio,
 		UserAgent:     DefaultUserAgent,
+		LoadBalancing: RoundRobin,
 		Cardinalities: map[string]int{
 			"app":       5,
 			"namespace": 10,
 			"pod":       50,
 		},
+		Patterns: Patterns{
+			Templates: flog.DefaultPatternTemplates,
+			Slots:     flog.DefaultPatternSlots,
+		},
 		RandSeed: time.Now().Unix(),
 	}
-	if len(c.Arguments) > 1 || c.Argument(0).ExportType().Kind() == reflect.String {
+	if kind := c.Argument(0).ExportType().Kind(); len(c.Arguments) > 1 || kind == reflect.String || kind == reflect.Slice {
 		if err := r.parsePositionalConfig(c, config); err != nil {
 			common.Throw(rt, fmt.Errorf("could not parse positional loki config: %w", err))
 		}
@@ -146,8 +245,8 @@ func (r *Loki) config(c sobek.ConstructorCall) *sobek.Object {
 	}
 
 	r.logger.Debug(fmt.Sprintf(
-		"url=%s timeout=%s protobufRatio=%f cardinalities=%v randSeed=%d",
-		&config.URL, config.Timeout, config.ProtobufRatio, config.Cardinalities, config.RandSeed,
+		"urls=%v loadBalancing=%s timeout=%s protobufRatio=%f cardinalities=%v randSeed=%d",
+		redactedURLs(config.URLs), config.LoadBalancing, config.Timeout, config.ProtobufRatio, config.Cardinalities, config.RandSeed,
 	))
 
 	if config.TenantID == "" {
@@ -160,15 +259,8 @@ func (r *Loki) config(c sobek.ConstructorCall) *sobek.Object {
 func (r *Loki) parsePositionalConfig(c sobek.ConstructorCall, config *Config) error {
 	rt := r.vu.Runtime()
 
-	urlString := c.Argument(0).String()
-	u, err := url.Parse(urlString)
-	if err != nil {
-		return fmt.Errorf("invalid loki URL: %w", err)
-	}
-	config.URL = *u
-
-	if user := u.User.Username(); user != "" {
-		config.TenantID = user
+	if err := parseURLs(rt, c.Argument(0), config); err != nil {
+		return err
 	}
 
 	if len(c.Arguments) > 1 {
@@ -194,6 +286,48 @@ func (r *Loki) parsePositionalConfig(c sobek.ConstructorCall, config *Config) er
 	return nil
 }
 
+// parseURLs parses either a single Loki URL or a list of Loki URLs into the
+// config. The tenant ID is taken from the first URL that contains a user.
+func parseURLs(rt *sobek.Runtime, v sobek.Value, config *Config) error {
+	var urls []string
+	if v.ExportType().Kind() == reflect.Slice {
+		if err := rt.ExportTo(v, &urls); err != nil {
+			return fmt.Errorf("url should be a string or a list of strings: %w", err)
+		}
+	} else {
+		urls = []string{v.String()}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("at least one loki URL is required")
+	}
+
+	config.URLs = make([]url.URL, 0, len(urls))
+	for _, urlString := range urls {
+		u, err := url.Parse(urlString)
+		if err != nil {
+			return fmt.Errorf("invalid loki URL: %w", err)
+		}
+		config.URLs = append(config.URLs, *u)
+		if len(config.URLs) == 1 {
+			config.URL = *u
+		}
+
+		if user := u.User.Username(); user != "" && config.TenantID == "" {
+			config.TenantID = user
+		}
+	}
+	return nil
+}
+
+// redactedURLs returns the URLs as strings, with the passwords redacted
+func redactedURLs(urls []url.URL) []string {
+	result := make([]string, 0, len(urls))
+	for _, u := range urls {
+		result = append(result, u.Redacted())
+	}
+	return result
+}
+
 func isNully(v sobek.Value) bool {
 	return v == nil || sobek.IsUndefined(v) || sobek.IsNull(v)
 }
@@ -201,15 +335,17 @@ func isNully(v sobek.Value) bool {
 func (r *Loki) parseConfigObject(c *sobek.Object, config *Config) error {
 	rt := r.vu.Runtime()
 	if v := c.Get("url"); !isNully(v) {
-		u, err := url.Parse(v.String())
-		if err != nil {
-			return fmt.Errorf("invalid loki URL: %w", err)
+		if err := parseURLs(rt, v, config); err != nil {
+			return err
 		}
-		config.URL = *u
+	}
 
-		if user := u.User.Username(); user != "" {
-			config.TenantID = user
+	if v := c.Get("loadBalancing"); !isNully(v) {
+		lb := LoadBalancing(v.String())
+		if !lb.isValid() {
+			return fmt.Errorf("invalid load balancing strategy %q, must be one of %v", lb, LoadBalancingStrategies)
 		}
+		config.LoadBalancing = lb
 	}
 
 	if v := c.Get("userAgent"); !isNully(v) {
@@ -231,6 +367,117 @@ func (r *Loki) parseConfigObject(c *sobek.Object, config *Config) error {
 		}
 	}
 
+	if v := c.Get("generators"); !isNully(v) {
+		if err := rt.ExportTo(v, &config.Generators); err != nil {
+			return fmt.Errorf("generators should be a map of string to string: %w", err)
+		}
+	}
+
+	if v := c.Get("distributions"); !isNully(v) {
+		if err := r.parseDistributions(v.ToObject(rt), config); err != nil {
+			return fmt.Errorf("could not parse distributions: %w", err)
+		}
+	}
+
+	if v := c.Get("churn"); !isNully(v) {
+		if err := r.parseChurn(v.ToObject(rt), config); err != nil {
+			return fmt.Errorf("could not parse churn: %w", err)
+		}
+	}
+
+	if v := c.Get("hierarchy"); !isNully(v) {
+		if err := r.parseHierarchy(v, config); err != nil {
+			return fmt.Errorf("could not parse hierarchy: %w", err)
+		}
+	}
+
+	if v := c.Get("rates"); !isNully(v) {
+		if err := r.parseRates(v, config); err != nil {
+			return fmt.Errorf("could not parse rates: %w", err)
+		}
+	}
+
+	if v := c.Get("lineLength"); !isNully(v) {
+		if err := r.parseLineLength(v.ToObject(rt), config); err != nil {
+			return fmt.Errorf("could not parse lineLength: %w", err)
+		}
+	}
+
+	if v := c.Get("stacktraceRatio"); !isNully(v) {
+		config.StackTraceRatio = v.ToFloat()
+		if config.StackTraceRatio < 0 || config.StackTraceRatio > 1 {
+			return fmt.Errorf("stacktraceRatio needs to be between 0 and 1, got %v", config.StackTraceRatio)
+		}
+	}
+
+	if v := c.Get("levels"); !isNully(v) {
+		if err := r.parseLevels(v.ToObject(rt), config); err != nil {
+			return fmt.Errorf("could not parse levels: %w", err)
+		}
+	}
+
+	if v := c.Get("traces"); !isNully(v) {
+		config.Traces = r.parseTraces(v.ToObject(rt))
+	}
+
+	if v := c.Get("needles"); !isNully(v) {
+		if err := r.parseNeedles(v, config); err != nil {
+			return fmt.Errorf("could not parse needles: %w", err)
+		}
+	}
+
+	if v := c.Get("json"); !isNully(v) {
+		config.JSON = r.parseJSONOptions(v.ToObject(rt))
+	}
+
+	if v := c.Get("logfmt"); !isNully(v) {
+		o, err := r.parseLogfmtOptions(v.ToObject(rt))
+		if err != nil {
+			return fmt.Errorf("could not parse logfmt options: %w", err)
+		}
+		config.Logfmt = o
+	}
+
+	if v := c.Get("repetition"); !isNully(v) {
+		o := v.ToObject(rt)
+		config.Repetition = &Repetition{Window: DefaultRepetitionWindow}
+		if v := o.Get("ratio"); !isNully(v) {
+			config.Repetition.Ratio = v.ToFloat()
+		}
+		if v := o.Get("window"); !isNully(v) {
+			config.Repetition.Window = int(v.ToInteger())
+		}
+	}
+
+	if v := c.Get("patterns"); !isNully(v) {
+		o := v.ToObject(rt)
+		if v := o.Get("templates"); !isNully(v) {
+			config.Patterns.Templates = int(v.ToInteger())
+		}
+		if v := o.Get("slots"); !isNully(v) {
+			config.Patterns.Slots = int(v.ToInteger())
+		}
+	}
+
+	if v := c.Get("fuzz"); !isNully(v) {
+		o := v.ToObject(rt)
+		config.Fuzz = &Fuzz{}
+		if v := o.Get("ratio"); !isNully(v) {
+			config.Fuzz.Ratio = v.ToFloat()
+		}
+		if v := o.Get("cases"); !isNully(v) {
+			if err := rt.ExportTo(v, &config.Fuzz.Cases); err != nil {
+				return fmt.Errorf("fuzz cases should be a list of strings: %w", err)
+			}
+		}
+	}
+
+	if v := c.Get("pool"); !isNully(v) {
+		if err := r.parsePool(v.ToObject(rt), config); err != nil {
+			return fmt.Errorf("could not parse pool: %w", err)
+		}
+	}
+
 	if v := c.Get("labels"); !isNully(v) {
 		if err := rt.ExportTo(v, &config.Labels); err != nil {
 			return fmt.Errorf("could not parse labels: %w", err)
@@ -245,6 +492,351 @@ func (r *Loki) parseConfigObject(c *sobek.Object, config *Config) error {
 		config.RandSeed = v.ToInteger()
 	}
 
+	if v := c.Get("retry"); !isNully(v) {
+		if err := r.parseRetryConfig(v.ToObject(rt), &config.Retry); err != nil {
+			return fmt.Errorf("could not parse retry config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Loki) parseDistributions(c *sobek.Object, config *Config) error {
+	rt := r.vu.Runtime()
+	config.Distributions = make(map[string]Distribution, len(c.Keys()))
+	for _, name := range c.Keys() {
+		o := c.Get(name).ToObject(rt)
+		d := Distribution{Type: DistributionUniform, V: 1}
+		if v := o.Get("type"); !isNully(v) {
+			d.Type = v.String()
+		}
+		if v := o.Get("s"); !isNully(v) {
+			d.S = v.ToFloat()
+		}
+		if v := o.Get("v"); !isNully(v) {
+			d.V = v.ToFloat()
+		}
+		if v := o.Get("weights"); !isNully(v) {
+			if err := rt.ExportTo(v, &d.Weights); err != nil {
+				return fmt.Errorf("weights of label %q should be a list of numbers: %w", name, err)
+			}
+		}
+		config.Distributions[name] = d
+	}
+	return nil
+}
+
+func (r *Loki) parseChurn(c *sobek.Object, config *Config) error {
+	rt := r.vu.Runtime()
+	config.Churn = make(map[string]Churn, len(c.Keys()))
+	for _, name := range c.Keys() {
+		o := c.Get(name).ToObject(rt)
+		ch := Churn{}
+		if v := o.Get("ratio"); !isNully(v) {
+			ch.Ratio = v.ToFloat()
+		}
+		if v := o.Get("interval"); !isNully(v) {
+			d, err := time.ParseDuration(v.String())
+			if err != nil {
+				return fmt.Errorf("invalid interval of label %q: %w", name, err)
+			}
+			ch.Interval = d
+		}
+		if v := o.Get("iterations"); !isNully(v) {
+			ch.Iterations = int(v.ToInteger())
+		}
+		config.Churn[name] = ch
+	}
+	return nil
+}
+
+func (r *Loki) parseHierarchy(v sobek.Value, config *Config) error {
+	rt := r.vu.Runtime()
+	var levels []sobek.Value
+	if err := rt.ExportTo(v, &levels); err != nil {
+		return fmt.Errorf("hierarchy should be a list of objects: %w", err)
+	}
+	config.Hierarchy = make([]HierarchyLevel, 0, len(levels))
+	for _, l := range levels {
+		o := l.ToObject(rt)
+		level := HierarchyLevel{}
+		if v := o.Get("name"); !isNully(v) {
+			level.Name = v.String()
+		}
+		if v := o.Get("cardinality"); !isNully(v) {
+			level.Cardinality = int(v.ToInteger())
+		}
+		if v := o.Get("values"); !isNully(v) {
+			if err := rt.ExportTo(v, &level.Values); err != nil {
+				return fmt.Errorf("values of label %q should be a list of strings: %w", level.Name, err)
+			}
+		}
+		config.Hierarchy = append(config.Hierarchy, level)
+	}
+	return nil
+}
+
+func (r *Loki) parseRates(v sobek.Value, config *Config) error {
+	rt := r.vu.Runtime()
+	var rates []sobek.Value
+	if err := rt.ExportTo(v, &rates); err != nil {
+		return fmt.Errorf("rates should be a list of objects: %w", err)
+	}
+	config.Rates = make([]StreamRate, 0, len(rates))
+	for _, rate := range rates {
+		o := rate.ToObject(rt)
+		sr := StreamRate{}
+		if v := o.Get("labels"); !isNully(v) {
+			var labels map[string]string
+			if err := rt.ExportTo(v, &labels); err != nil {
+				return fmt.Errorf("labels should be a map of string to string: %w", err)
+			}
+			sr.Labels = make(model.LabelSet, len(labels))
+			for k, v := range labels {
+				sr.Labels[model.LabelName(k)] = model.LabelValue(v)
+			}
+		}
+		if v := o.Get("linesPerSecond"); !isNully(v) {
+			sr.LinesPerSecond = v.ToFloat()
+		}
+		if v := o.Get("bytesPerSecond"); !isNully(v) {
+			sr.BytesPerSecond = v.ToFloat()
+		}
+		config.Rates = append(config.Rates, sr)
+	}
+	return nil
+}
+
+func (r *Loki) parsePool(c *sobek.Object, config *Config) error {
+	p := &Pool{
+		Name:    DefaultPoolName,
+		Size:    DefaultPoolSize,
+		Streams: 5,
+		MinSize: 800 * 1024,
+		MaxSize: 1024 * 1024,
+	}
+	if v := c.Get("name"); !isNully(v) {
+		p.Name = v.String()
+	}
+	if v := c.Get("size"); !isNully(v) {
+		p.Size = int(v.ToInteger())
+	}
+	if v := c.Get("streams"); !isNully(v) {
+		p.Streams = int(v.ToInteger())
+	}
+	if v := c.Get("minSize"); !isNully(v) {
+		p.MinSize = int(v.ToInteger())
+	}
+	if v := c.Get("maxSize"); !isNully(v) {
+		p.MaxSize = int(v.ToInteger())
+	}
+	if len(config.Rates) > 0 || len(config.Needles) > 0 {
+		return fmt.Errorf("pool cannot be used together with rates or needles")
+	}
+	config.Pool = p
+	return nil
+}
+
+func (r *Loki) parseLineLength(c *sobek.Object, config *Config) error {
+	rt := r.vu.Runtime()
+	l := &LineLength{Distribution: LineLengthFixed}
+	if v := c.Get("distribution"); !isNully(v) {
+		l.Distribution = v.String()
+	}
+	if v := c.Get("size"); !isNully(v) {
+		l.Size = int(v.ToInteger())
+	}
+	if v := c.Get("min"); !isNully(v) {
+		l.Min = int(v.ToInteger())
+	}
+	if v := c.Get("max"); !isNully(v) {
+		l.Max = int(v.ToInteger())
+	}
+	if v := c.Get("mean"); !isNully(v) {
+		l.Mean = v.ToFloat()
+	}
+	if v := c.Get("stddev"); !isNully(v) {
+		l.StdDev = v.ToFloat()
+	}
+	if v := c.Get("buckets"); !isNully(v) {
+		var buckets []sobek.Value
+		if err := rt.ExportTo(v, &buckets); err != nil {
+			return fmt.Errorf("buckets should be a list of objects: %w", err)
+		}
+		for _, b := range buckets {
+			o := b.ToObject(rt)
+			bucket := LineLengthBucket{Weight: 1}
+			if v := o.Get("size"); !isNully(v) {
+				bucket.Size = int(v.ToInteger())
+			}
+			if v := o.Get("weight"); !isNully(v) {
+				bucket.Weight = v.ToFloat()
+			}
+			l.Buckets = append(l.Buckets, bucket)
+		}
+	}
+	if v := c.Get("oversizeRatio"); !isNully(v) {
+		l.OversizeRatio = v.ToFloat()
+	}
+	if v := c.Get("oversizeSize"); !isNully(v) {
+		l.OversizeSize = int(v.ToInteger())
+	}
+	config.LineLength = l
+	return nil
+}
+
+func (r *Loki) parseLevels(c *sobek.Object, config *Config) error {
+	rt := r.vu.Runtime()
+	levels := &Levels{}
+	if v := c.Get("weights"); !isNully(v) {
+		if err := rt.ExportTo(v, &levels.Weights); err != nil {
+			return fmt.Errorf("weights should be a map of string to numbers: %w", err)
+		}
+	} else {
+		levels.Weights = make(map[string]float64, len(flog.Levels))
+		for i, level := range flog.Levels {
+			levels.Weights[level] = flog.DefaultLevelWeights[i]
+		}
+	}
+	if v := c.Get("incident"); !isNully(v) {
+		o := v.ToObject(rt)
+		incident := &Incident{}
+		for key, d := range map[string]*time.Duration{"interval": &incident.Interval, "duration": &incident.Duration} {
+			if v := o.Get(key); !isNully(v) {
+				parsed, err := time.ParseDuration(v.String())
+				if err != nil {
+					return fmt.Errorf("invalid incident %s: %w", key, err)
+				}
+				*d = parsed
+			}
+		}
+		if v := o.Get("errorRatio"); !isNully(v) {
+			incident.ErrorRatio = v.ToFloat()
+		}
+		levels.Incident = incident
+	}
+	config.Levels = levels
+	return nil
+}
+
+func (r *Loki) parseTraces(c *sobek.Object) *Traces {
+	traces := &Traces{Ratio: 1, PoolSize: DefaultTracePoolSize, Mode: TraceModeField}
+	if v := c.Get("ratio"); !isNully(v) {
+		traces.Ratio = v.ToFloat()
+	}
+	if v := c.Get("poolSize"); !isNully(v) {
+		traces.PoolSize = int(v.ToInteger())
+	}
+	if v := c.Get("seed"); !isNully(v) {
+		traces.Seed = v.ToInteger()
+	}
+	if v := c.Get("mode"); !isNully(v) {
+		traces.Mode = v.String()
+	}
+	return traces
+}
+
+func (r *Loki) parseNeedles(v sobek.Value, config *Config) error {
+	rt := r.vu.Runtime()
+	var needles []sobek.Value
+	if err := rt.ExportTo(v, &needles); err != nil {
+		return fmt.Errorf("needles should be a list of objects: %w", err)
+	}
+	config.Needles = make([]Needle, 0, len(needles))
+	for _, n := range needles {
+		o := n.ToObject(rt)
+		needle := Needle{}
+		if v := o.Get("token"); !isNully(v) {
+			needle.Token = v.String()
+		}
+		if v := o.Get("every"); !isNully(v) {
+			needle.Every = int(v.ToInteger())
+		}
+		config.Needles = append(config.Needles, needle)
+	}
+	return nil
+}
+
+func (r *Loki) parseJSONOptions(c *sobek.Object) *flog.JSONOptions {
+	o := flog.DefaultJSONOptions
+	if v := c.Get("depth"); !isNully(v) {
+		o.Depth = int(v.ToInteger())
+	}
+	if v := c.Get("keys"); !isNully(v) {
+		o.Keys = int(v.ToInteger())
+	}
+	if v := c.Get("keyCardinality"); !isNully(v) {
+		o.KeyCardinality = int(v.ToInteger())
+	}
+	if v := c.Get("arrayRatio"); !isNully(v) {
+		o.ArrayRatio = v.ToFloat()
+	}
+	if v := c.Get("objectRatio"); !isNully(v) {
+		o.ObjectRatio = v.ToFloat()
+	}
+	return &o
+}
+
+func (r *Loki) parseLogfmtOptions(c *sobek.Object) (*flog.LogfmtOptions, error) {
+	rt := r.vu.Runtime()
+	o := flog.DefaultLogfmtOptions
+	if v := c.Get("keys"); !isNully(v) {
+		o.Keys = int(v.ToInteger())
+	}
+	if v := c.Get("keyNames"); !isNully(v) {
+		if err := rt.ExportTo(v, &o.KeyNames); err != nil {
+			return nil, fmt.Errorf("keyNames should be a list of strings: %w", err)
+		}
+	}
+	if v := c.Get("keyCardinality"); !isNully(v) {
+		o.KeyCardinality = int(v.ToInteger())
+	}
+	if v := c.Get("cardinalities"); !isNully(v) {
+		o.Cardinalities = nil
+		if err := rt.ExportTo(v, &o.Cardinalities); err != nil {
+			return nil, fmt.Errorf("cardinalities should be a map of string to integers: %w", err)
+		}
+	}
+	return &o, nil
+}
+
+func (r *Loki) parseRetryConfig(c *sobek.Object, retry *RetryConfig) error {
+	rt := r.vu.Runtime()
+	*retry = RetryConfig{
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		RetryOn:    DefaultRetryOn,
+	}
+
+	if v := c.Get("maxRetries"); !isNully(v) {
+		retry.MaxRetries = int(v.ToInteger())
+	}
+
+	if v := c.Get("minBackoff"); !isNully(v) {
+		retry.MinBackoff = time.Duration(v.ToInteger()) * time.Millisecond
+	}
+
+	if v := c.Get("maxBackoff"); !isNully(v) {
+		retry.MaxBackoff = time.Duration(v.ToInteger()) * time.Millisecond
+	}
+
+	if retry.MinBackoff > retry.MaxBackoff {
+		return fmt.Errorf("minBackoff needs to be smaller or equal to maxBackoff")
+	}
+
+	if v := c.Get("retryOn"); !isNully(v) {
+		var values []interface{}
+		if err := rt.ExportTo(v, &values); err != nil {
+			return fmt.Errorf("retryOn should be a list of status codes: %w", err)
+		}
+		codes, err := parseRetryOn(values)
+		if err != nil {
+			return err
+		}
+		retry.RetryOn = codes
+	}
+
 	return nil
 }
 
@@ -259,25 +851,140 @@ func (r *Loki) client(c sobek.ConstructorCall) *sobek.Object {
 		common.Throw(rt, fmt.Errorf("Client constructor expect Config as it's argument"))
 	}
 
+	client, err := newClient(r.vu, r.metrics, config)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	if config.Pool != nil {
+		client.pool, err = r.pools.get(config)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("invalid pool: %w", err))
+		}
+	}
+	return rt.ToValue(client).ToObject(rt)
+}
+
+// newClient creates a client with the given config. The VU is nil for
+// clients that only generate batches, see batchPool.
+func newClient(vu modules.VU, m lokiMetrics, config *Config) (*Client, error) {
 	rand := rand.New(rand.NewSource(config.RandSeed))
 	faker := gofakeit.NewCustom(rand)
 
-	flog := flog.New(rand, faker)
+	var flogOpts []flog.Option
+	if config.Levels != nil {
+		levels, err := newLevelSampler(rand, *config.Levels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid levels: %w", err)
+		}
+		flogOpts = append(flogOpts, flog.WithLevel(levels.sample))
+	}
+	if config.JSON != nil {
+		if err := config.JSON.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid json options: %w", err)
+		}
+		flogOpts = append(flogOpts, flog.WithJSONOptions(*config.JSON))
+	}
+	if config.Logfmt != nil {
+		if err := config.Logfmt.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid logfmt options: %w", err)
+		}
+		flogOpts = append(flogOpts, flog.WithLogfmtOptions(*config.Logfmt))
+	}
+	flog := flog.New(rand, faker, flogOpts...)
 
 	if len(config.Labels) == 0 {
-		config.Labels = newLabelPool(faker, config.Cardinalities)
-	}
-
-	return rt.ToValue(&Client{
-		client:  &http.Client{},
-		cfg:     config,
-		vu:      r.vu,
-		metrics: r.metrics,
-		rand:    rand,
-		faker:   faker,
-		flog:    flog,
-		labels:  transformLabelPool(config.Labels),
-	}).ToObject(rt)
+		labels, err := newLabelPool(faker, config.Cardinalities, config.Generators)
+		if err != nil {
+			return nil, fmt.Errorf("could not create label pool: %w", err)
+		}
+		config.Labels = labels
+		config.generatedLabels = true
+	}
+
+	var hierarchy *labelHierarchy
+	if len(config.Hierarchy) > 0 {
+		h, err := newLabelHierarchy(faker, config.Hierarchy, config.Generators)
+		if err != nil {
+			return nil, fmt.Errorf("could not create label hierarchy: %w", err)
+		}
+		hierarchy = h
+	}
+
+	labels := withoutHierarchy(transformLabelPool(config.Labels), hierarchy)
+	if err := applyDistributions(rand, labels, hierarchy, config.Distributions); err != nil {
+		return nil, err
+	}
+	if err := applyChurn(faker, labels, hierarchy, config.Churn, config.Generators, time.Now()); err != nil {
+		return nil, err
+	}
+
+	rates, err := newStreamRates(config.Rates, hierarchy)
+	if err != nil {
+		return nil, err
+	}
+
+	var traces *tracePool
+	if config.Traces != nil {
+		traces, err = newTracePool(*config.Traces)
+		if err != nil {
+			return nil, fmt.Errorf("invalid traces: %w", err)
+		}
+	}
+
+	needles, err := newNeedles(config.Needles, faker.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := newStreamPatterns(config.Patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patterns: %w", err)
+	}
+
+	var fuzzer *fuzzer
+	if config.Fuzz != nil {
+		fuzzer, err = newFuzzer(*config.Fuzz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fuzz: %w", err)
+		}
+	}
+
+	var repetition *repetition
+	if config.Repetition != nil {
+		repetition, err = newRepetition(*config.Repetition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repetition: %w", err)
+		}
+	}
+
+	var lineLength *lineLength
+	if config.LineLength != nil {
+		lineLength, err = newLineLength(rand, *config.LineLength)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lineLength: %w", err)
+		}
+	}
+
+	return &Client{
+		client:          &http.Client{},
+		cfg:             config,
+		vu:              vu,
+		metrics:         m,
+		rand:            rand,
+		faker:           faker,
+		flog:            flog,
+		labels:          labels,
+		hierarchy:       hierarchy,
+		rates:           rates,
+		lineLength:      lineLength,
+		stacktraceRatio: config.StackTraceRatio,
+		traces:          traces,
+		needles:         needles,
+		repetition:      repetition,
+		patterns:        patterns,
+		fuzzer:          fuzzer,
+		stats:           newWriteStats(),
+	}, nil
 }
 
 func (r *Loki) createLabels(c sobek.ConstructorCall) *sobek.Object {
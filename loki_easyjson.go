@@ -60,37 +60,16 @@ func easyjson3fd435f7DecodeGithubComGrafanaXk6Loki(in *jlexer.Lexer, out *JSONSt
 				in.Delim('[')
 				if out.Values == nil {
 					if !in.IsDelim(']') {
-						out.Values = make([][]string, 0, 2)
+						out.Values = make([]JSONEntry, 0, 1)
 					} else {
-						out.Values = [][]string{}
+						out.Values = []JSONEntry{}
 					}
 				} else {
 					out.Values = (out.Values)[:0]
 				}
 				for !in.IsDelim(']') {
-					var v2 []string
-					if in.IsNull() {
-						in.Skip()
-						v2 = nil
-					} else {
-						in.Delim('[')
-						if v2 == nil {
-							if !in.IsDelim(']') {
-								v2 = make([]string, 0, 4)
-							} else {
-								v2 = []string{}
-							}
-						} else {
-							v2 = (v2)[:0]
-						}
-						for !in.IsDelim(']') {
-							var v3 string
-							v3 = string(in.String())
-							v2 = append(v2, v3)
-							in.WantComma()
-						}
-						in.Delim(']')
-					}
+					var v2 JSONEntry
+					(v2).UnmarshalEasyJSON(in)
 					out.Values = append(out.Values, v2)
 					in.WantComma()
 				}
@@ -117,16 +96,16 @@ func easyjson3fd435f7EncodeGithubComGrafanaXk6Loki(out *jwriter.Writer, in JSONS
 			out.RawString(`null`)
 		} else {
 			out.RawByte('{')
-			v4First := true
-			for v4Name, v4Value := range in.Stream {
-				if v4First {
-					v4First = false
+			v3First := true
+			for v3Name, v3Value := range in.Stream {
+				if v3First {
+					v3First = false
 				} else {
 					out.RawByte(',')
 				}
-				out.String(string(v4Name))
+				out.String(string(v3Name))
 				out.RawByte(':')
-				out.String(string(v4Value))
+				out.String(string(v3Value))
 			}
 			out.RawByte('}')
 		}
@@ -138,22 +117,11 @@ func easyjson3fd435f7EncodeGithubComGrafanaXk6Loki(out *jwriter.Writer, in JSONS
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v5, v6 := range in.Values {
-				if v5 > 0 {
+			for v4, v5 := range in.Values {
+				if v4 > 0 {
 					out.RawByte(',')
 				}
-				if v6 == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
-					out.RawString("null")
-				} else {
-					out.RawByte('[')
-					for v7, v8 := range v6 {
-						if v7 > 0 {
-							out.RawByte(',')
-						}
-						out.String(string(v8))
-					}
-					out.RawByte(']')
-				}
+				(v5).MarshalEasyJSON(out)
 			}
 			out.RawByte(']')
 		}
@@ -205,9 +173,9 @@ func easyjson3fd435f7DecodeGithubComGrafanaXk6Loki1(in *jlexer.Lexer, out *JSONP
 					out.Streams = (out.Streams)[:0]
 				}
 				for !in.IsDelim(']') {
-					var v9 JSONStream
-					(v9).UnmarshalEasyJSON(in)
-					out.Streams = append(out.Streams, v9)
+					var v6 JSONStream
+					(v6).UnmarshalEasyJSON(in)
+					out.Streams = append(out.Streams, v6)
 					in.WantComma()
 				}
 				in.Delim(']')
@@ -233,11 +201,11 @@ func easyjson3fd435f7EncodeGithubComGrafanaXk6Loki1(out *jwriter.Writer, in JSON
 			out.RawString("null")
 		} else {
 			out.RawByte('[')
-			for v10, v11 := range in.Streams {
-				if v10 > 0 {
+			for v7, v8 := range in.Streams {
+				if v7 > 0 {
 					out.RawByte(',')
 				}
-				(v11).MarshalEasyJSON(out)
+				(v8).MarshalEasyJSON(out)
 			}
 			out.RawByte(']')
 		}
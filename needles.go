@@ -0,0 +1,161 @@
+package loki
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+// Needle defines a token that is added to every n-th log line, so queries can
+// search for lines with a known frequency.
+type Needle struct {
+	// Token is added as `needle` field to the log lines. A random UUID is
+	// used if it is empty.
+	Token string
+	// Every is the frequency of the token, e.g. 10000 to add the token to
+	// one in 10000 lines
+	Every int
+}
+
+// NeedleStats holds the lines with a needle that were written by a client.
+// They are exposed to the Javascript runtime via `client.needles()`.
+type NeedleStats struct {
+	Token string `js:"token"`
+	Every int    `js:"every"`
+	// Lines is the number of lines written by the client that contain the
+	// token
+	Lines int64 `js:"lines"`
+	// Start is the timestamp of the first line that contains the token, and
+	// End the timestamp after the last line that contains the token, in
+	// milliseconds since the Unix epoch. Start is rounded down and End is
+	// rounded up, so a query from Start to End (exclusive) includes all
+	// lines.
+	Start int64 `js:"start"`
+	End   int64 `js:"end"`
+
+	start, end time.Time
+}
+
+// needle keeps track of the lines since the last line with the token, and the
+// lines with the token that were successfully pushed.
+type needle struct {
+	Needle
+	lines   int
+	written NeedleStats
+	// logfmt and json are the fields added by flog.AppendField to lines
+	// in logfmt and JSON format
+	logfmt string
+	json   string
+}
+
+func newNeedles(needles []Needle, uuid func() string) ([]*needle, error) {
+	result := make([]*needle, 0, len(needles))
+	for i, n := range needles {
+		if n.Every <= 0 {
+			return nil, fmt.Errorf("needle %d requires every > 0, got %d", i, n.Every)
+		}
+		if n.Token == "" {
+			n.Token = uuid()
+		}
+		json := flog.AppendField("{}", "needle", n.Token)
+		result = append(result, &needle{
+			Needle:  n,
+			written: NeedleStats{Token: n.Token, Every: n.Every},
+			logfmt:  flog.AppendField("", "needle", n.Token),
+			json:    json[1 : len(json)-1],
+		})
+	}
+	return result, nil
+}
+
+// addNeedles adds the tokens that are due to the log entry
+func (c *Client) addNeedles(entry *push.Entry) {
+	for _, n := range c.needles {
+		n.lines++
+		if n.lines == n.Every {
+			n.lines = 0
+			entry.Line = flog.AppendField(entry.Line, "needle", n.Token)
+		}
+	}
+}
+
+// findNeedles counts the lines of the batch that contain a token, and
+// records the time range of these lines.
+func (c *Client) findNeedles(batch *Batch) {
+	if len(c.needles) == 0 {
+		return
+	}
+	batch.needles = make(map[string]*NeedleStats, len(c.needles))
+	for _, n := range c.needles {
+		stats := &NeedleStats{Token: n.Token, Every: n.Every}
+		for _, stream := range batch.Streams {
+			for _, entry := range stream.Entries {
+				if n.in(entry.Line) {
+					stats.add(1, entry.Timestamp, entry.Timestamp)
+				}
+			}
+		}
+		batch.needles[n.Token] = stats
+	}
+}
+
+// in returns whether the line contains the needle field. The field needs to
+// be followed by a delimiter, so that tokens that are a prefix of another
+// token do not match.
+func (n *needle) in(line string) bool {
+	return containsField(line, n.logfmt, " \n") || containsField(line, n.json, ",}")
+}
+
+// containsField returns whether the line contains the field followed by the end
+// of the line or one of the delimiters
+func containsField(line, field, delimiters string) bool {
+	for {
+		i := strings.Index(line, field)
+		if i < 0 {
+			return false
+		}
+		line = line[i+len(field):]
+		if line == "" || strings.IndexByte(delimiters, line[0]) >= 0 {
+			return true
+		}
+	}
+}
+
+// add adds lines with the given time range
+func (s *NeedleStats) add(lines int64, start, end time.Time) {
+	if lines == 0 {
+		return
+	}
+	if s.Lines == 0 || start.Before(s.start) {
+		s.start = start
+		s.Start = start.UnixMilli()
+	}
+	if s.Lines == 0 || end.After(s.end) {
+		s.end = end
+		s.End = end.UnixMilli() + 1
+	}
+	s.Lines += lines
+}
+
+// recordNeedles accounts the lines with needles of a successfully pushed
+// batch
+func (c *Client) recordNeedles(batch *Batch) {
+	for _, n := range c.needles {
+		if s, ok := batch.needles[n.Token]; ok && s.Lines > 0 {
+			n.written.add(s.Lines, s.start, s.end)
+		}
+	}
+}
+
+// Needles returns the lines with needles that were successfully pushed by the
+// client.
+func (c *Client) Needles() []NeedleStats {
+	result := make([]NeedleStats, 0, len(c.needles))
+	for _, n := range c.needles {
+		result = append(result, n.written)
+	}
+	return result
+}
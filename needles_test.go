@@ -0,0 +1,88 @@
+package loki
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+func TestNeedles(t *testing.T) {
+	faker := gofakeit.New(12345)
+	needles, err := newNeedles([]Needle{{Token: "needle-a", Every: 10}, {Every: 7}}, faker.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if needles[1].Token == "" {
+		t.Fatal("expected random token for needle without token")
+	}
+	c := Client{
+		vu:      &modulestest.VU{CtxField: context.Background(), StateField: &lib.State{VUID: 1}},
+		rand:    faker.Rand,
+		faker:   faker,
+		flog:    flog.New(faker.Rand, faker),
+		labels:  transformLabelPool(LabelPool{"format": {"logfmt"}, "app": {"api", "web"}}),
+		needles: needles,
+	}
+
+	lines := 0
+	for i := 0; i < 5; i++ {
+		batch, err := c.newBatch(2, 50000, 50000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines += batch.lines()
+		c.recordNeedles(batch)
+	}
+
+	for _, s := range c.Needles() {
+		if expected := int64(lines / s.Every); s.Lines != expected {
+			t.Errorf("expected %d lines with token %s, got %d", expected, s.Token, s.Lines)
+		}
+		if s.Start == 0 || s.End < s.Start {
+			t.Errorf("expected valid time range, got %d-%d", s.Start, s.End)
+		}
+	}
+}
+
+func TestNewNeedlesInvalid(t *testing.T) {
+	if _, err := newNeedles([]Needle{{Token: "foo"}}, nil); err == nil {
+		t.Fatal("expected error for needle without frequency")
+	}
+}
+
+func TestFindNeedlesPrefixTokens(t *testing.T) {
+	needles, err := newNeedles([]Needle{{Token: "abc", Every: 1000}, {Token: "abcd", Every: 1000}, {Token: "a b", Every: 1000}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{needles: needles}
+	ts := time.Unix(0, 1700000000123456789)
+	batch := &Batch{Streams: map[string]*push.Stream{}}
+	batch.stream(model.LabelSet{"app": "api"}).Entries = []push.Entry{
+		{Timestamp: ts, Line: flog.AppendField("foo", "needle", "abcd")},
+		{Timestamp: ts, Line: flog.AppendField(`{"msg":"foo"}`, "needle", "abcd")},
+		{Timestamp: ts.Add(time.Second), Line: flog.AppendField(flog.AppendField("foo", "needle", "abc"), "trace_id", "1")},
+		{Timestamp: ts, Line: flog.AppendField(`{"msg":"foo"}`, "needle", "a b")},
+	}
+	c.findNeedles(batch)
+
+	for token, lines := range map[string]int64{"abc": 1, "abcd": 2, "a b": 1} {
+		if s := batch.needles[token]; s.Lines != lines {
+			t.Errorf("expected %d lines with token %s, got %d", lines, token, s.Lines)
+		}
+	}
+	s := batch.needles["abc"]
+	if last := ts.Add(time.Second); s.End <= last.UnixMilli() || time.UnixMilli(s.End).Sub(last) > time.Millisecond {
+		t.Errorf("expected end to be rounded up to the millisecond after %d, got %d", last.UnixMilli(), s.End)
+	}
+	if s.Start != ts.Add(time.Second).UnixMilli() {
+		t.Errorf("expected start %d, got %d", ts.Add(time.Second).UnixMilli(), s.Start)
+	}
+}
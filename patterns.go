@@ -0,0 +1,58 @@
+package loki
+
+import (
+	"fmt"
+
+	"github.com/grafana/xk6-loki/flog"
+	"github.com/prometheus/common/model"
+)
+
+// Patterns defines the templates of the `pattern` log format. Each stream has
+// its own templates, which only depend on the labels of the stream.
+type Patterns struct {
+	// Templates is the number of templates per stream
+	Templates int
+	// Slots is the number of variable slots per template
+	Slots int
+}
+
+// streamPatterns keeps the templates of the streams
+type streamPatterns struct {
+	Patterns
+	streams map[uint64]*flog.Patterns
+}
+
+func newStreamPatterns(p Patterns) (*streamPatterns, error) {
+	if p.Templates <= 0 {
+		return nil, fmt.Errorf("templates needs to be greater than 0, got %d", p.Templates)
+	}
+	if p.Slots < 0 {
+		return nil, fmt.Errorf("slots must not be negative, got %d", p.Slots)
+	}
+	return &streamPatterns{Patterns: p, streams: map[uint64]*flog.Patterns{}}, nil
+}
+
+// get returns the templates of the stream with the given labels. The
+// instance label is ignored, so the streams of all VUs with the same labels
+// have the same templates.
+func (p *streamPatterns) get(labels model.LabelSet) *flog.Patterns {
+	ls := labels.Clone()
+	delete(ls, model.InstanceLabel)
+	hash := hashLabels(ls.String())
+	patterns, ok := p.streams[hash]
+	if !ok {
+		patterns = flog.NewPatterns(int64(hash), p.Templates, p.Slots)
+		p.streams[hash] = patterns
+	}
+	return patterns
+}
+
+// Patterns returns the templates of the stream with the given labels, with
+// `<_>` for the variable slots.
+func (c *Client) Patterns(labels map[string]string) []string {
+	ls := make(model.LabelSet, len(labels))
+	for k, v := range labels {
+		ls[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return c.patterns.get(ls).Templates()
+}
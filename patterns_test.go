@@ -0,0 +1,76 @@
+package loki
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+	"github.com/prometheus/common/model"
+)
+
+// matchTemplate returns whether the line matches the template, where `<_>`
+// matches any token
+func matchTemplate(template, line string) bool {
+	tokens := strings.Fields(template)
+	words := strings.Fields(line)
+	if len(tokens) != len(words) {
+		return false
+	}
+	for i, token := range tokens {
+		if token != "<_>" && token != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPatterns(t *testing.T) {
+	faker := gofakeit.New(12345)
+	patterns, err := newStreamPatterns(Patterns{Templates: 5, Slots: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker), patterns: patterns}
+
+	labels := model.LabelSet{"app": "api", "format": "pattern", "instance": "vu1.localhost"}
+	templates := c.Patterns(map[string]string{"app": "api", "format": "pattern"})
+	if len(templates) != 5 {
+		t.Fatalf("expected 5 templates, got %d", len(templates))
+	}
+
+	other := c.Patterns(map[string]string{"app": "web", "format": "pattern"})
+	if slices.Equal(templates, other) {
+		t.Fatal("expected streams with different labels to have different templates")
+	}
+
+	seen := map[string]struct{}{}
+	for i := 0; i < 200; i++ {
+		line := c.logEntry(labels, "pattern", time.Now()).Line
+		matched := false
+		for _, template := range templates {
+			if matchTemplate(template, line) {
+				seen[template] = struct{}{}
+				matched = true
+			}
+		}
+		if !matched {
+			t.Fatalf("expected line %q to match one of the templates %v", line, templates)
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("expected lines of all 5 templates, got %d", len(seen))
+	}
+
+	// the templates do not depend on the client or the instance
+	patterns, err = newStreamPatterns(Patterns{Templates: 5, Slots: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other = (&Client{patterns: patterns}).Patterns(map[string]string{"app": "api", "format": "pattern", "instance": "vu2.localhost"})
+	if !slices.Equal(templates, other) {
+		t.Errorf("expected the same templates for the same stream, got %v and %v", templates, other)
+	}
+}
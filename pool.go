@@ -0,0 +1,389 @@
+package loki
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/mailru/easyjson/jwriter"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	DefaultPoolName = "default"
+	DefaultPoolSize = 20
+)
+
+// poolInstance is the value of the instance label of the streams of pooled
+// batches. It is replaced with the instance of the VU that pushes the batch.
+const poolInstance = model.LabelValue("pool")
+
+// Pool defines a pool of batches that are generated once in the background
+// and shared by all VUs, so `push()` does not pay for generating log lines.
+type Pool struct {
+	// Name identifies the pool. Clients with the same pool name share the
+	// pool, which is generated with the config of the first client.
+	Name string
+	// Size is the number of batches in the pool
+	Size int
+	// Streams, MinSize and MaxSize are the parameters of the generated
+	// batches, see PushParameterized
+	Streams int
+	MinSize int
+	MaxSize int
+}
+
+// batchPool holds the generated batches of a Pool. Batches are drawn round
+// robin, once at least one batch is generated.
+type batchPool struct {
+	// ready is closed once the first batch is generated, or generating it
+	// failed
+	ready   chan struct{}
+	mu      sync.RWMutex
+	batches []*pooledBatch
+	err     error
+	next    atomic.Uint64
+	// config is the config of the batches, see poolConfig
+	config Config
+}
+
+// newBatchPool starts to generate the batches of the pool in a background
+// goroutine, using a separate client that is not bound to a VU
+func newBatchPool(config *Config) (*batchPool, error) {
+	p := config.Pool
+	if p.Size < 1 {
+		return nil, fmt.Errorf("size needs to be at least 1, got %d", p.Size)
+	}
+	if p.Streams < 1 {
+		return nil, fmt.Errorf("streams needs to be at least 1, got %d", p.Streams)
+	}
+	if p.MinSize > p.MaxSize {
+		return nil, errors.New("minimum batch size needs to be smaller or equal to max batch size")
+	}
+
+	cfg := *config
+	cfg.Pool = nil
+	gen, err := newClient(nil, lokiMetrics{}, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &batchPool{ready: make(chan struct{})}
+	go pool.generate(gen, *p)
+	return pool, nil
+}
+
+func (p *batchPool) generate(gen *Client, cfg Pool) {
+	var once sync.Once
+	defer once.Do(func() { close(p.ready) })
+	for i := 0; i < cfg.Size; i++ {
+		batch, err := gen.newBatch(cfg.Streams, cfg.MinSize, cfg.MaxSize)
+		var pooled *pooledBatch
+		if err == nil {
+			pooled, err = newPooledBatch(batch)
+		}
+		p.mu.Lock()
+		if err != nil {
+			p.err = err
+			p.mu.Unlock()
+			return
+		}
+		p.batches = append(p.batches, pooled)
+		p.mu.Unlock()
+		once.Do(func() { close(p.ready) })
+	}
+}
+
+// draw returns the next batch of the pool. It blocks until the first batch of
+// the pool is generated.
+func (p *batchPool) draw() (*pooledBatch, error) {
+	<-p.ready
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.batches) == 0 {
+		return nil, fmt.Errorf("could not generate batch pool: %w", p.err)
+	}
+	n := p.next.Add(1) - 1
+	return p.batches[n%uint64(len(p.batches))], nil
+}
+
+// batchPools are the pools shared by all VUs, by name
+type batchPools struct {
+	mu    sync.Mutex
+	pools map[string]*batchPool
+}
+
+// get returns the pool of the config, and creates it if it does not exist
+// yet. Clients share a pool by name, so an error is returned if the batches of
+// the configs differ.
+func (p *batchPools) get(config *Config) (*batchPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cfg := poolConfig(config)
+	if pool, ok := p.pools[config.Pool.Name]; ok {
+		if !reflect.DeepEqual(pool.config, cfg) {
+			return nil, fmt.Errorf("pool %q is already used by a client with a different configuration, use a different pool name", config.Pool.Name)
+		}
+		return pool, nil
+	}
+	pool, err := newBatchPool(config)
+	if err != nil {
+		return nil, err
+	}
+	pool.config = cfg
+	if p.pools == nil {
+		p.pools = make(map[string]*batchPool)
+	}
+	p.pools[config.Pool.Name] = pool
+	return pool, nil
+}
+
+// poolConfig returns the parts of the config that affect the generated batches.
+// The random seed, and the labels that are generated with it, are not part of
+// it, since the seed defaults to the time the config was created, which
+// differs between VUs.
+func poolConfig(config *Config) Config {
+	cfg := *config
+	if cfg.generatedLabels {
+		cfg.Labels = nil
+		cfg.generatedLabels = false
+	}
+	cfg.URL, cfg.URLs = url.URL{}, nil
+	cfg.LoadBalancing = ""
+	cfg.UserAgent = ""
+	cfg.Timeout = 0
+	cfg.TenantID = ""
+	cfg.ProtobufRatio = 0
+	cfg.RandSeed = 0
+	cfg.Retry = RetryConfig{}
+	return cfg
+}
+
+// pooledBatch is a batch of a pool. The entries of its streams are encoded
+// once without their timestamps, so pushing the batch only needs to encode
+// the timestamps and the labels.
+type pooledBatch struct {
+	batch   *Batch
+	streams []*pooledStream
+}
+
+// pooledStream is a stream of a pooledBatch
+type pooledStream struct {
+	key     string
+	entries []push.Entry
+	// proto are the protobuf encoded entries, without the timestamp field
+	proto [][]byte
+	// json are the JSON encoded entries, without the opening bracket and the
+	// timestamp
+	json [][]byte
+}
+
+func newPooledBatch(batch *Batch) (*pooledBatch, error) {
+	p := &pooledBatch{batch: batch, streams: make([]*pooledStream, 0, len(batch.Streams))}
+	for key, stream := range batch.Streams {
+		s := &pooledStream{
+			key:     key,
+			entries: stream.Entries,
+			proto:   make([][]byte, 0, len(stream.Entries)),
+			json:    make([][]byte, 0, len(stream.Entries)),
+		}
+		for _, entry := range stream.Entries {
+			buf, err := entry.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			// the timestamp is the first field of the entry
+			if len(buf) == 0 || buf[0] != 0x0a {
+				return nil, errors.New("entry does not start with a timestamp")
+			}
+			size, n := binary.Uvarint(buf[1:])
+			if n <= 0 {
+				return nil, errors.New("invalid timestamp of entry")
+			}
+			s.proto = append(s.proto, buf[1+n+int(size):])
+
+			w := jwriter.Writer{}
+			JSONEntry{Line: entry.Line, StructuredMetadata: structuredMetadataToMap(entry.StructuredMetadata)}.marshalTail(&w)
+			s.json = append(s.json, w.Buffer.BuildBytes())
+		}
+		p.streams = append(p.streams, s)
+	}
+	return p, nil
+}
+
+// instanceStream is a stream of a pooledBatch with the instance label of a
+// client. Its entries are shared with the pool and must not be modified.
+type instanceStream struct {
+	stream *push.Stream
+	labels model.LabelSet
+	// jsonPrefix is the JSON encoded stream up to its first value
+	jsonPrefix []byte
+}
+
+func newInstanceStream(batch *Batch, s *pooledStream, instance model.LabelValue) *instanceStream {
+	labels := batch.labels[s.key].Clone()
+	if labels[model.InstanceLabel] == poolInstance {
+		labels[model.InstanceLabel] = instance
+	}
+	w := jwriter.Writer{}
+	w.RawString(`{"stream":`)
+	w.RawByte('{')
+	first := true
+	for name, value := range labels {
+		if !first {
+			w.RawByte(',')
+		}
+		first = false
+		w.String(string(name))
+		w.RawByte(':')
+		w.String(string(value))
+	}
+	w.RawString(`},"values":[`)
+	return &instanceStream{
+		stream:     &push.Stream{Labels: labels.String(), Entries: s.entries},
+		labels:     labels,
+		jsonPrefix: w.Buffer.BuildBytes(),
+	}
+}
+
+// drawnBatch is attached to the batches that are drawn from a pool
+type drawnBatch struct {
+	pooled *pooledBatch
+	// streams are the streams of the pooled batch with the instance label of
+	// the client that pushes the batch
+	streams []*instanceStream
+	// offset is added to the timestamps of the pooled entries
+	offset time.Duration
+}
+
+// drawBatch returns a batch that is drawn from the pool of the client. The
+// timestamps of the batch are shifted so the batch is created at the given
+// time, and the pool instance label is replaced with the instance of the
+// client.
+func (c *Client) drawBatch(now time.Time, instance model.LabelValue) (*Batch, error) {
+	pooled, err := c.pool.draw()
+	if err != nil {
+		return nil, err
+	}
+	if c.poolStreams == nil {
+		c.poolStreams = make(map[*pooledStream]*instanceStream)
+	}
+	batch := &Batch{
+		Streams:   make(map[string]*push.Stream, len(pooled.streams)),
+		labels:    make(map[string]model.LabelSet, len(pooled.streams)),
+		Bytes:     pooled.batch.Bytes,
+		CreatedAt: now,
+		fuzzed:    pooled.batch.fuzzed,
+		drawn: &drawnBatch{
+			pooled:  pooled,
+			streams: make([]*instanceStream, 0, len(pooled.streams)),
+			offset:  now.Sub(pooled.batch.CreatedAt),
+		},
+	}
+	for _, s := range pooled.streams {
+		is, ok := c.poolStreams[s]
+		if !ok {
+			is = newInstanceStream(pooled.batch, s, instance)
+			c.poolStreams[s] = is
+		}
+		batch.Streams[is.stream.Labels] = is.stream
+		batch.labels[is.stream.Labels] = is.labels
+		batch.drawn.streams = append(batch.drawn.streams, is)
+	}
+	return batch, nil
+}
+
+// encodeProtobuf returns the protobuf encoded push request of the batch and
+// the number of encoded entries. Only the labels and the timestamps are
+// encoded, the entries are copied from the pool.
+func (d *drawnBatch) encodeProtobuf() ([]byte, int) {
+	size := 0
+	for i := range d.streams {
+		size += fieldSize(d.streamSize(i))
+	}
+	buf := make([]byte, 0, size)
+	entriesCount := 0
+	for i, s := range d.pooled.streams {
+		labels := d.streams[i].stream.Labels
+		buf = appendField(buf, 0x0a, d.streamSize(i))
+		buf = appendField(buf, 0x0a, len(labels))
+		buf = append(buf, labels...)
+		for j, entry := range s.proto {
+			ts := s.entries[j].Timestamp.Add(d.offset)
+			tsSize := push.SizeOfStdTime(ts)
+			buf = appendField(buf, 0x12, fieldSize(tsSize)+len(entry))
+			buf = appendField(buf, 0x0a, tsSize)
+			buf = buf[:len(buf)+tsSize]
+			_, _ = push.StdTimeMarshalTo(ts, buf[len(buf)-tsSize:])
+			buf = append(buf, entry...)
+		}
+		entriesCount += len(s.proto)
+	}
+	return buf, entriesCount
+}
+
+// streamSize returns the size of the i-th protobuf encoded stream
+func (d *drawnBatch) streamSize(i int) int {
+	s := d.pooled.streams[i]
+	size := fieldSize(len(d.streams[i].stream.Labels))
+	for j, entry := range s.proto {
+		tsSize := push.SizeOfStdTime(s.entries[j].Timestamp.Add(d.offset))
+		size += fieldSize(fieldSize(tsSize) + len(entry))
+	}
+	return size
+}
+
+// encodeJSON returns the JSON encoded push request of the batch and the
+// number of encoded entries
+func (d *drawnBatch) encodeJSON() ([]byte, int) {
+	size := len(`{"streams":[]}`)
+	for i, s := range d.pooled.streams {
+		size += len(d.streams[i].jsonPrefix) + len(`]},`)
+		for _, entry := range s.json {
+			size += len(`["1234567890123456789",`) + len(entry)
+		}
+	}
+	buf := make([]byte, 0, size)
+	entriesCount := 0
+	buf = append(buf, `{"streams":[`...)
+	for i, s := range d.pooled.streams {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, d.streams[i].jsonPrefix...)
+		for j, entry := range s.json {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, `["`...)
+			buf = strconv.AppendInt(buf, s.entries[j].Timestamp.Add(d.offset).UnixNano(), 10)
+			buf = append(buf, '"')
+			buf = append(buf, entry...)
+		}
+		buf = append(buf, `]}`...)
+		entriesCount += len(s.json)
+	}
+	buf = append(buf, `]}`...)
+	return buf, entriesCount
+}
+
+// appendField appends the tag and the length of a length-delimited protobuf
+// field
+func appendField(buf []byte, tag byte, size int) []byte {
+	buf = append(buf, tag)
+	return binary.AppendUvarint(buf, uint64(size))
+}
+
+// fieldSize returns the size of a length-delimited protobuf field with a one
+// byte tag and the given size of the value
+func fieldSize(size int) int {
+	return 1 + (bits.Len64(uint64(size)|1)+6)/7 + size
+}
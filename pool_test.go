@@ -0,0 +1,183 @@
+package loki
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	json "github.com/mailru/easyjson"
+)
+
+func TestBatchPool(t *testing.T) {
+	config := &Config{
+		Cardinalities: map[string]int{"app": 2, "namespace": 2, "pod": 5},
+		Generators:    map[string]string{"namespace": `{{fake "Company"}}, "ns"`},
+		Patterns:      Patterns{Templates: flog.DefaultPatternTemplates, Slots: flog.DefaultPatternSlots},
+		Traces:        &Traces{Ratio: 0.5, PoolSize: 10, Mode: TraceModeMetadata},
+		RandSeed:      12345,
+		Pool:          &Pool{Name: DefaultPoolName, Size: 3, Streams: 2, MinSize: 1000, MaxSize: 2000},
+	}
+	var pools batchPools
+	pool, err := pools.get(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, err := pools.get(config); err != nil || other != pool {
+		t.Fatalf("expected the same pool, got %v (err=%v)", other, err)
+	}
+	c := Client{pool: pool}
+
+	for i := 0; i < 6; i++ {
+		now := time.Now().Add(time.Duration(i) * time.Hour)
+		batch, err := c.drawBatch(now, "vu1.localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !batch.CreatedAt.Equal(now) {
+			t.Fatalf("expected batch to be created at %s, got %s", now, batch.CreatedAt)
+		}
+		offset := now.Sub(batch.drawn.pooled.batch.CreatedAt)
+
+		// expected entries by labels and line, with shifted timestamps
+		expected := map[string]push.Entry{}
+		for labels, stream := range batch.Streams {
+			if !strings.Contains(labels, `instance="vu1.localhost"`) || stream.Labels != labels {
+				t.Fatalf("expected instance label to be replaced, got %s", labels)
+			}
+			for _, entry := range stream.Entries {
+				entry.Timestamp = entry.Timestamp.Add(offset)
+				expected[labels+entry.Line] = entry
+			}
+		}
+
+		buf, n, _, err := batch.encodeSnappy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := snappy.Decode(nil, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var req push.PushRequest
+		if err := req.Unmarshal(raw); err != nil {
+			t.Fatal(err)
+		}
+		if n != batch.lines() || len(req.Streams) != len(batch.Streams) {
+			t.Fatalf("expected %d entries in %d streams, got %d entries in %d streams", batch.lines(), len(batch.Streams), n, len(req.Streams))
+		}
+		for _, stream := range req.Streams {
+			for _, entry := range stream.Entries {
+				e, ok := expected[stream.Labels+entry.Line]
+				if !ok || !e.Timestamp.Equal(entry.Timestamp) || !slices.Equal(e.StructuredMetadata, entry.StructuredMetadata) {
+					t.Fatalf("unexpected protobuf entry %v of stream %s, expected %v", entry, stream.Labels, e)
+				}
+			}
+		}
+
+		buf, n, err = batch.encodeJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var jsonReq JSONPushRequest
+		if err := json.Unmarshal(buf, &jsonReq); err != nil {
+			t.Fatalf("invalid JSON %s: %v", buf, err)
+		}
+		lines := 0
+		for _, stream := range jsonReq.Streams {
+			if !strings.HasSuffix(stream.Stream["namespace"], `, "ns"`) || stream.Stream["instance"] != "vu1.localhost" {
+				t.Fatalf("unexpected labels %v", stream.Stream)
+			}
+			lines += len(stream.Values)
+			for _, value := range stream.Values {
+				found := false
+				for _, e := range expected {
+					if e.Line == value.Line && strconv.FormatInt(e.Timestamp.UnixNano(), 10) == value.Timestamp && maps.Equal(structuredMetadataToMap(e.StructuredMetadata), value.StructuredMetadata) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Fatalf("unexpected JSON entry %v", value)
+				}
+			}
+		}
+		if n != lines || lines != batch.lines() {
+			t.Fatalf("expected %d JSON entries, got %d (%d)", batch.lines(), lines, n)
+		}
+	}
+
+	pool.mu.RLock()
+	for _, pooled := range pool.batches {
+		for labels := range pooled.batch.Streams {
+			if !strings.Contains(labels, `instance="pool"`) {
+				t.Fatalf("expected pooled batches to be unchanged, got %s", labels)
+			}
+		}
+	}
+	pool.mu.RUnlock()
+}
+
+func TestBatchPoolErrors(t *testing.T) {
+	for name, pool := range map[string]Pool{
+		"size":    {Size: 0, Streams: 1},
+		"streams": {Size: 1, Streams: 0},
+		"sizes":   {Size: 1, Streams: 1, MinSize: 2, MaxSize: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newBatchPool(&Config{Pool: &pool}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestBatchPoolsConfigMismatch(t *testing.T) {
+	newConfig := func(seed int64, host string) *Config {
+		return &Config{
+			URLs:          []url.URL{{Scheme: "http", Host: host}},
+			Cardinalities: map[string]int{"app": 2},
+			Patterns:      Patterns{Templates: flog.DefaultPatternTemplates, Slots: flog.DefaultPatternSlots},
+			RandSeed:      seed,
+			Pool:          &Pool{Name: DefaultPoolName, Size: 1, Streams: 1, MinSize: 100, MaxSize: 100},
+		}
+	}
+	var pools batchPools
+	first := newConfig(1, "loki-0:3100")
+	pool, err := pools.get(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the labels are generated with the random seed of each client
+	if _, err := newClient(nil, lokiMetrics{}, first); err != nil {
+		t.Fatal(err)
+	}
+	if other, err := pools.get(first); err != nil || other != pool {
+		t.Fatalf("expected the same pool for the same config, got %v (err=%v)", other, err)
+	}
+
+	// clients of other VUs have a different seed and endpoint
+	second := newConfig(2, "loki-1:3100")
+	if _, err := newClient(nil, lokiMetrics{}, second); err != nil {
+		t.Fatal(err)
+	}
+	if other, err := pools.get(second); err != nil || other != pool {
+		t.Fatalf("expected the same pool for a config with another seed and endpoint, got %v (err=%v)", other, err)
+	}
+
+	different := newConfig(1, "loki-0:3100")
+	different.Cardinalities["app"] = 3
+	if _, err := pools.get(different); err == nil {
+		t.Fatal("expected error for the same pool name with a different configuration")
+	}
+	different.Pool.Name = "other"
+	if other, err := pools.get(different); err != nil || other == pool {
+		t.Fatalf("expected a different pool for a different name, got %v (err=%v)", other, err)
+	}
+}
@@ -0,0 +1,186 @@
+package loki
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/metrics"
+)
+
+// maxRateBacklog is the maximum time span of lines or bytes that is generated
+// for a rate in a single batch. The credit of a longer time span, e.g. when
+// the VU was blocked by retries, is shed, so that batches do not grow without
+// bounds.
+const maxRateBacklog = 10 * time.Second
+
+// StreamRate defines the throughput of the streams with the given labels,
+// either in lines per second or in bytes per second.
+type StreamRate struct {
+	// Labels are the label values that the stream has. The values of all
+	// other labels are chosen randomly for each batch.
+	Labels         model.LabelSet
+	LinesPerSecond float64
+	BytesPerSecond float64
+}
+
+// streamRate keeps track of the lines or bytes that need to be generated for
+// a StreamRate.
+type streamRate struct {
+	StreamRate
+	// credit are the lines or bytes that were not generated yet. It can be
+	// negative, if more bytes were generated than allotted.
+	credit float64
+	last   time.Time
+}
+
+// newStreamRates validates the rates. The hierarchical labels of a rate need to
+// match a combination of the label hierarchy, and must not churn, since the
+// combination would disappear.
+func newStreamRates(rates []StreamRate, h *labelHierarchy) ([]*streamRate, error) {
+	result := make([]*streamRate, 0, len(rates))
+	for i, r := range rates {
+		if err := r.Labels.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid labels of rate %d: %w", i, err)
+		}
+		if (r.LinesPerSecond > 0) == (r.BytesPerSecond > 0) {
+			return nil, fmt.Errorf("rate %d requires either linesPerSecond or bytesPerSecond", i)
+		}
+		if h != nil {
+			if last := h.names[len(h.names)-1]; h.churn != nil && r.Labels[last] != "" {
+				return nil, fmt.Errorf("labels of rate %d cannot define the value of label %q, since its values churn", i, last)
+			}
+			if len(h.matching(r.Labels)) == 0 {
+				return nil, fmt.Errorf("labels %s of rate %d do not match any combination of the label hierarchy", r.Labels, i)
+			}
+		}
+		result = append(result, &streamRate{StreamRate: r})
+	}
+	return result, nil
+}
+
+// allot adds the credit for the time since the last batch and returns the
+// start of the time range of the batch, and the lines or bytes that were shed.
+// The first batch only starts the clock.
+func (r *streamRate) allot(now time.Time) (time.Time, float64) {
+	start := r.last
+	if start.IsZero() {
+		start = now
+	}
+	r.last = now
+
+	rate := r.LinesPerSecond
+	if rate <= 0 {
+		rate = r.BytesPerSecond
+	}
+	shed := 0.0
+	elapsed := now.Sub(start)
+	if elapsed > maxRateBacklog {
+		shed = rate * (elapsed - maxRateBacklog).Seconds()
+		start = now.Add(-maxRateBacklog)
+		elapsed = maxRateBacklog
+	}
+	r.credit += rate * elapsed.Seconds()
+	return start, shed
+}
+
+// addRatedStreams adds one stream per configured rate to the batch, with as
+// many entries as were allotted since the last batch. The timestamps of the
+// entries are spread evenly across the time since the last batch.
+func (c *Client) addRatedStreams(batch *Batch, instance model.LabelValue) error {
+	now := batch.CreatedAt
+	for _, r := range c.rates {
+		start, shed := r.allot(now)
+		if shed > 0 {
+			c.reportShedRate(r, shed)
+		}
+		if r.credit < 1 {
+			continue
+		}
+
+		labels := c.getRandomLabelSet()
+		if c.hierarchy != nil {
+			// select a combination of the hierarchy with the values
+			// of the rate, instead of overwriting the values of a
+			// random one
+			leaves := c.hierarchy.matching(r.Labels)
+			if len(leaves) == 0 {
+				return fmt.Errorf("labels %s of rate do not match any combination of the label hierarchy", r.Labels)
+			}
+			leaf := leaves[c.rand.Intn(len(leaves))]
+			for i, name := range c.hierarchy.names {
+				labels[name] = leaf[i]
+			}
+		}
+		for k, v := range r.Labels {
+			labels[k] = v
+		}
+		if _, ok := labels[model.InstanceLabel]; !ok {
+			labels[model.InstanceLabel] = instance
+		}
+		logFmt, err := streamFormat(labels)
+		if err != nil {
+			return err
+		}
+
+		// streams of different rates may have the same labels
+		stream := batch.stream(labels)
+
+		var entries []push.Entry
+		if r.LinesPerSecond > 0 {
+			n := int(r.credit)
+			r.credit -= float64(n)
+			for i := 0; i < n; i++ {
+				entry := c.logEntry(labels, logFmt, now)
+				entries = append(entries, entry)
+				batch.Bytes += len(entry.Line)
+			}
+		} else {
+			for r.credit > 0 {
+				entry := c.logEntry(labels, logFmt, now)
+				entries = append(entries, entry)
+				batch.Bytes += len(entry.Line)
+				r.credit -= float64(len(entry.Line))
+			}
+		}
+
+		step := now.Sub(start) / time.Duration(len(entries))
+		for i := range entries {
+			entries[i].Timestamp = start.Add(step * time.Duration(i+1))
+		}
+		// the entries of the stream may be newer than the rated entries,
+		// if the stream was generated randomly as well
+		sorted := len(stream.Entries) == 0
+		stream.Entries = append(stream.Entries, entries...)
+		if !sorted {
+			sort.SliceStable(stream.Entries, func(i, j int) bool {
+				return stream.Entries[i].Timestamp.Before(stream.Entries[j].Timestamp)
+			})
+		}
+	}
+	return nil
+}
+
+// reportShedRate reports the lines or bytes of a rate that were shed, because
+// more than maxRateBacklog passed since the previous batch.
+func (c *Client) reportShedRate(r *streamRate, shed float64) {
+	if c.vu == nil || c.vu.State() == nil {
+		return
+	}
+	ctm := c.vu.State().Tags.GetCurrentValues()
+	metric := c.metrics.ClientShedBytes
+	if r.LinesPerSecond > 0 {
+		metric = c.metrics.ClientShedLines
+	}
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: metric,
+			Tags:   ctm.Tags,
+		},
+		Metadata: ctm.Metadata,
+		Value:    shed,
+		Time:     time.Now(),
+	})
+}
@@ -0,0 +1,181 @@
+package loki
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	"github.com/prometheus/common/model"
+)
+
+func TestAddRatedStreams(t *testing.T) {
+	rates, err := newStreamRates([]StreamRate{
+		{Labels: model.LabelSet{"app": "api"}, BytesPerSecond: 10000},
+		{Labels: model.LabelSet{"app": "batch"}, LinesPerSecond: 10},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faker := gofakeit.New(12345)
+	c := Client{
+		rand:   faker.Rand,
+		faker:  faker,
+		flog:   flog.New(faker.Rand, faker),
+		labels: transformLabelPool(LabelPool{"format": {"logfmt"}, "app": {"foo"}}),
+		rates:  rates,
+	}
+
+	start := time.Now()
+	newBatch := func(now time.Time) *Batch {
+		batch := &Batch{Streams: map[string]*push.Stream{}, CreatedAt: now}
+		if err := c.addRatedStreams(batch, "localhost"); err != nil {
+			t.Fatal(err)
+		}
+		return batch
+	}
+
+	if batch := newBatch(start); len(batch.Streams) != 0 {
+		t.Fatalf("expected first batch to be empty, got %d streams", len(batch.Streams))
+	}
+
+	batch := newBatch(start.Add(2 * time.Second))
+	api := batch.Streams[`{app="api", format="logfmt", instance="localhost"}`]
+	bulk := batch.Streams[`{app="batch", format="logfmt", instance="localhost"}`]
+	if api == nil || bulk == nil {
+		t.Fatalf("expected streams for app=api and app=batch, got %v", batch.Streams)
+	}
+	if len(bulk.Entries) != 20 {
+		t.Errorf("expected 20 lines for app=batch, got %d", len(bulk.Entries))
+	}
+	bytes := 0
+	for _, e := range api.Entries {
+		bytes += len(e.Line)
+	}
+	if bytes < 20000 || bytes > 21000 {
+		t.Errorf("expected ~20000 bytes for app=api, got %d", bytes)
+	}
+	first, last := bulk.Entries[0].Timestamp, bulk.Entries[len(bulk.Entries)-1].Timestamp
+	if !first.Equal(start.Add(100*time.Millisecond)) || !last.Equal(start.Add(2*time.Second)) {
+		t.Errorf("expected timestamps to be spread across the last 2s, got %s to %s", first, last)
+	}
+}
+
+func TestNewStreamRatesErrors(t *testing.T) {
+	for name, rate := range map[string]StreamRate{
+		"no rate":       {Labels: model.LabelSet{"app": "api"}},
+		"both rates":    {Labels: model.LabelSet{"app": "api"}, LinesPerSecond: 1, BytesPerSecond: 1},
+		"invalid label": {Labels: model.LabelSet{"": "api"}, LinesPerSecond: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newStreamRates([]StreamRate{rate}, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestStreamRateBacklog(t *testing.T) {
+	rates, err := newStreamRates([]StreamRate{{Labels: model.LabelSet{"app": "api"}, LinesPerSecond: 10}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rates[0]
+	start := time.Now()
+	r.allot(start)
+
+	now := start.Add(30 * time.Second)
+	from, shed := r.allot(now)
+	if shed != 200 || r.credit != 100 {
+		t.Errorf("expected 100 lines of credit and 200 shed lines, got %v and %v", r.credit, shed)
+	}
+	if !from.Equal(now.Add(-maxRateBacklog)) {
+		t.Errorf("expected lines to start %s before the batch, got %s", maxRateBacklog, now.Sub(from))
+	}
+}
+
+func TestAddRatedStreamsOrder(t *testing.T) {
+	rates, err := newStreamRates([]StreamRate{{Labels: model.LabelSet{"app": "api"}, LinesPerSecond: 10}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faker := gofakeit.New(12345)
+	c := Client{
+		rand:   faker.Rand,
+		faker:  faker,
+		flog:   flog.New(faker.Rand, faker),
+		labels: transformLabelPool(LabelPool{"format": {"logfmt"}, "app": {"api"}}),
+		rates:  rates,
+	}
+	start := time.Now()
+	rates[0].allot(start)
+
+	// the randomly generated stream has the same labels as the rated stream
+	now := start.Add(time.Second)
+	batch := &Batch{Streams: map[string]*push.Stream{}, CreatedAt: now}
+	batch.stream(model.LabelSet{"app": "api", "format": "logfmt", "instance": "localhost"}).Entries = []push.Entry{{Timestamp: now.Add(time.Millisecond), Line: "random"}}
+	if err := c.addRatedStreams(batch, "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Streams) != 1 {
+		t.Fatalf("expected a single stream, got %d", len(batch.Streams))
+	}
+	for _, s := range batch.Streams {
+		if len(s.Entries) != 11 || s.Entries[10].Line != "random" {
+			t.Fatalf("expected the random entry to be the last of 11 entries, got %d entries", len(s.Entries))
+		}
+		for i := 1; i < len(s.Entries); i++ {
+			if s.Entries[i].Timestamp.Before(s.Entries[i-1].Timestamp) {
+				t.Fatalf("expected entries to be ordered by timestamp, got %s before %s", s.Entries[i-1].Timestamp, s.Entries[i].Timestamp)
+			}
+		}
+	}
+}
+
+func TestAddRatedStreamsHierarchy(t *testing.T) {
+	faker := gofakeit.New(12345)
+	h, err := newLabelHierarchy(faker, []HierarchyLevel{
+		{Name: "namespace", Values: []string{"dev", "prod"}},
+		{Name: "pod", Cardinality: 3},
+	}, map[string]string{"pod": "{{.namespace}}-{{hex 4}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates, err := newStreamRates([]StreamRate{{Labels: model.LabelSet{"namespace": "prod"}, LinesPerSecond: 1}}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{
+		rand:      faker.Rand,
+		faker:     faker,
+		flog:      flog.New(faker.Rand, faker),
+		labels:    transformLabelPool(LabelPool{"format": {"logfmt"}}),
+		hierarchy: h,
+		rates:     rates,
+	}
+	start := time.Now()
+	rates[0].allot(start)
+	for i := 1; i <= 20; i++ {
+		batch := &Batch{Streams: map[string]*push.Stream{}, CreatedAt: start.Add(time.Duration(i) * time.Second)}
+		if err := c.addRatedStreams(batch, "localhost"); err != nil {
+			t.Fatal(err)
+		}
+		for key, labels := range batch.labels {
+			if !strings.HasPrefix(string(labels["pod"]), "prod-") {
+				t.Fatalf("expected a pod of namespace prod, got %s", key)
+			}
+		}
+	}
+
+	if _, err := newStreamRates([]StreamRate{{Labels: model.LabelSet{"namespace": "staging"}, LinesPerSecond: 1}}, h); err == nil {
+		t.Error("expected error for labels that do not match the hierarchy")
+	}
+	if err := applyChurn(faker, nil, h, map[string]Churn{"pod": {Ratio: 0.5, Iterations: 1}}, nil, start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newStreamRates([]StreamRate{{Labels: model.LabelSet{"pod": h.leaves[0][1]}, LinesPerSecond: 1}}, h); err == nil {
+		t.Error("expected error for labels with churn")
+	}
+}
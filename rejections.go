@@ -0,0 +1,50 @@
+package loki
+
+import (
+	"strings"
+)
+
+// ReasonOther is the rejection reason of failed push requests whose error
+// message does not match any of the known reasons.
+const ReasonOther = "other"
+
+// rejectionReasons maps substrings of the (lowercased) error messages Loki
+// returns for push requests to the reason that is used by Loki's
+// `loki_discarded_samples_total` metric.
+var rejectionReasons = []struct {
+	substring string
+	reason    string
+}{
+	{"ingestion rate limit exceeded", "rate_limited"},
+	{"per stream rate limit exceeded", "per_stream_rate_limit"},
+	{"maximum active stream limit exceeded", "stream_limit"},
+	{"max entry size", "line_too_long"},
+	{"entry out of order", "out_of_order"},
+	{"entry too far behind", "too_far_behind"},
+	{"timestamp too old", "greater_than_max_sample_age"},
+	{"timestamp too new", "too_far_in_future"},
+	{"label names; limit", "max_label_names_per_series"},
+	{"label name too long", "label_name_too_long"},
+	{"label value too long", "label_value_too_long"},
+	{"duplicate label name", "duplicate_label_names"},
+	{"error parsing labels", "invalid_labels"},
+	{"at least one label pair is required", "missing_labels"},
+}
+
+// classifyPushError returns the distinct rejection reasons that are contained
+// in the error body of a failed push request. A single response may contain
+// multiple reasons, since Loki reports errors per stream. If no known reason
+// is found, ReasonOther is returned.
+func classifyPushError(body string) []string {
+	body = strings.ToLower(body)
+	var reasons []string
+	for _, r := range rejectionReasons {
+		if strings.Contains(body, r.substring) {
+			reasons = append(reasons, r.reason)
+		}
+	}
+	if len(reasons) == 0 {
+		return []string{ReasonOther}
+	}
+	return reasons
+}
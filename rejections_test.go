@@ -0,0 +1,47 @@
+package loki
+
+import (
+	"testing"
+)
+
+func TestClassifyPushError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		expected []string
+	}{
+		"rate limited": {
+			body:     "Ingestion rate limit exceeded for user xk6-tenant-1 (limit: 4194304 bytes/sec) while attempting to ingest '1000' lines totaling '1048576' bytes",
+			expected: []string{"rate_limited"},
+		},
+		"stream limit": {
+			body:     `Maximum active stream limit exceeded when trying to create stream {app="foo"}, reduce the number of active streams`,
+			expected: []string{"stream_limit"},
+		},
+		"multiple reasons": {
+			body: "entry with timestamp 2024-01-01 00:00:00 +0000 UTC ignored, reason: 'entry out of order',\n" +
+				"entry for stream '{app=\"foo\"}' has timestamp too old: 2023-01-01T00:00:00Z, oldest acceptable timestamp is: 2023-12-25T00:00:00Z\n" +
+				"total ignored: 2 out of 100",
+			expected: []string{"out_of_order", "greater_than_max_sample_age"},
+		},
+		"line too long": {
+			body:     `Max entry size '262144' bytes exceeded for stream '{app="foo"}' while adding an entry with length '300000' bytes`,
+			expected: []string{"line_too_long"},
+		},
+		"unknown": {
+			body:     "upstream connect error",
+			expected: []string{ReasonOther},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reasons := classifyPushError(tc.body)
+			if len(reasons) != len(tc.expected) {
+				t.Fatalf("expected reasons %v, got %v", tc.expected, reasons)
+			}
+			for i := range reasons {
+				if reasons[i] != tc.expected[i] {
+					t.Fatalf("expected reasons %v, got %v", tc.expected, reasons)
+				}
+			}
+		})
+	}
+}
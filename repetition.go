@@ -0,0 +1,66 @@
+package loki
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const DefaultRepetitionWindow = 100
+
+// Repetition defines how often recently generated log lines are repeated
+// verbatim, which makes the log content more compressible.
+type Repetition struct {
+	// Ratio is the ratio of log lines that repeat a recent line
+	Ratio float64
+	// Window is the number of recent lines per format that can be repeated.
+	// Lines of the pattern format are repeated per stream.
+	Window int
+}
+
+// repetition keeps the recently generated lines per format, or per stream for
+// the pattern format
+type repetition struct {
+	Repetition
+	recent map[string]*lineRing
+}
+
+// lineRing is a ring buffer of log lines
+type lineRing struct {
+	lines []string
+	next  int
+}
+
+func newRepetition(r Repetition) (*repetition, error) {
+	if r.Ratio < 0 || r.Ratio > 1 {
+		return nil, fmt.Errorf("ratio needs to be between 0 and 1, got %v", r.Ratio)
+	}
+	if r.Window <= 0 {
+		return nil, fmt.Errorf("window needs to be greater than 0, got %d", r.Window)
+	}
+	return &repetition{Repetition: r, recent: map[string]*lineRing{}}, nil
+}
+
+// repeat returns a random recent line with the given key for the given ratio
+// of lines
+func (r *repetition) repeat(rand *rand.Rand, key string) (string, bool) {
+	ring, ok := r.recent[key]
+	if !ok || rand.Float64() >= r.Ratio {
+		return "", false
+	}
+	return ring.lines[rand.Intn(len(ring.lines))], true
+}
+
+// add adds a generated line with the given key
+func (r *repetition) add(key, line string) {
+	ring, ok := r.recent[key]
+	if !ok {
+		ring = &lineRing{lines: make([]string, 0, r.Window)}
+		r.recent[key] = ring
+	}
+	if len(ring.lines) < r.Window {
+		ring.lines = append(ring.lines, line)
+		return
+	}
+	ring.lines[ring.next] = line
+	ring.next = (ring.next + 1) % r.Window
+}
@@ -0,0 +1,102 @@
+package loki
+
+import (
+	"context"
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+func TestRepetitionIncreasesCompressionRatio(t *testing.T) {
+	compressionRatio := func(r *Repetition) float64 {
+		faker := gofakeit.New(12345)
+		c := Client{
+			vu:     &modulestest.VU{CtxField: context.Background(), StateField: &lib.State{VUID: 1}},
+			rand:   faker.Rand,
+			faker:  faker,
+			flog:   flog.New(faker.Rand, faker),
+			labels: transformLabelPool(LabelPool{"format": {"json"}}),
+		}
+		if r != nil {
+			repetition, err := newRepetition(*r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.repetition = repetition
+		}
+		batch, err := c.newBatch(1, 100000, 100000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, ratio, err := batch.encodeSnappy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ratio
+	}
+
+	base := compressionRatio(nil)
+	repeated := compressionRatio(&Repetition{Ratio: 0.9, Window: 10})
+	if repeated < 2*base {
+		t.Fatalf("expected repetition to at least double the compression ratio of %v, got %v", base, repeated)
+	}
+}
+
+func TestRepetitionWindow(t *testing.T) {
+	r, err := newRepetition(Repetition{Ratio: 1, Window: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	faker := gofakeit.New(1)
+	if _, ok := r.repeat(faker.Rand, "json"); ok {
+		t.Fatal("expected no line to repeat without previous lines")
+	}
+	for _, line := range []string{"a", "b", "c"} {
+		r.add("json", line)
+	}
+	for i := 0; i < 100; i++ {
+		if line, _ := r.repeat(faker.Rand, "json"); line == "a" {
+			t.Fatal("expected line outside of window to not be repeated")
+		}
+	}
+	if _, ok := r.repeat(faker.Rand, "logfmt"); ok {
+		t.Fatal("expected lines to be repeated only within the same format")
+	}
+}
+
+func TestRepetitionPatternPerStream(t *testing.T) {
+	faker := gofakeit.New(12345)
+	r, err := newRepetition(Repetition{Ratio: 0.9, Window: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns, err := newStreamPatterns(Patterns{Templates: flog.DefaultPatternTemplates, Slots: flog.DefaultPatternSlots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{
+		vu:         &modulestest.VU{CtxField: context.Background(), StateField: &lib.State{VUID: 1}},
+		rand:       faker.Rand,
+		faker:      faker,
+		flog:       flog.New(faker.Rand, faker),
+		labels:     transformLabelPool(LabelPool{"format": {"pattern"}, "app": {"api", "web"}}),
+		patterns:   patterns,
+		repetition: r,
+	}
+	batch, err := c.newBatch(2, 100000, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := map[string]string{}
+	for key, stream := range batch.Streams {
+		for _, e := range stream.Entries {
+			if other, ok := lines[e.Line]; ok && other != key {
+				t.Fatalf("expected lines to repeat only within a stream, got %q in %s and %s", e.Line, other, key)
+			}
+			lines[e.Line] = key
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	DefaultMaxRetries = 10
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Minute
+	DefaultRetryOn    = []string{"429", "5xx"}
+)
+
+// RetryConfig defines how failed push requests are retried. The semantics
+// follow the ones of the Promtail and Grafana Alloy Loki clients: failed
+// requests are retried with exponential backoff and the batch is dropped once
+// the maximum amount of retries is reached.
+type RetryConfig struct {
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// RetryOn contains the status codes that are retried, either as exact
+	// code, e.g. `429`, or as class, e.g. `5xx`.
+	RetryOn []string
+}
+
+// shouldRetry returns whether a push request that failed with the given status
+// code should be retried. A status of 0 means that no response was received,
+// which is always retried.
+func (r RetryConfig) shouldRetry(status int) bool {
+	if r.MaxRetries <= 0 {
+		return false
+	}
+	if status == 0 {
+		return true
+	}
+	for _, code := range r.RetryOn {
+		if strings.HasSuffix(code, "xx") {
+			if code[:len(code)-2] == strconv.Itoa(status/100) {
+				return true
+			}
+		} else if code == strconv.Itoa(status) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the duration to wait before the next retry. The backoff
+// doubles with each attempt, starting with MinBackoff and capped at
+// MaxBackoff. The returned duration is jittered between half and the full
+// backoff.
+func (r RetryConfig) backoff(rand *rand.Rand, attempt int) time.Duration {
+	d := r.MaxBackoff
+	if attempt < 63 {
+		if b := r.MinBackoff << attempt; b > 0 && b < r.MaxBackoff {
+			d = b
+		}
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half))
+	}
+	return d
+}
+
+// delay returns the duration to wait before the next retry. The Retry-After
+// header of the response is honoured if present, but capped at MaxBackoff, so
+// a server cannot stall the VU. Otherwise the exponential backoff is used.
+func (r RetryConfig) delay(rand *rand.Rand, attempt int, headers map[string]string, now time.Time) time.Duration {
+	d, ok := retryAfter(headers, now)
+	if !ok {
+		return r.backoff(rand, attempt)
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return d
+}
+
+func validRetryCode(code string) bool {
+	if strings.HasSuffix(code, "xx") && len(code) == 3 {
+		n, err := strconv.Atoi(code[:1])
+		return err == nil && n >= 1 && n <= 5
+	}
+	n, err := strconv.Atoi(code)
+	return err == nil && n >= 100 && n <= 599
+}
+
+// parseRetryOn converts a list of status codes or status code classes into
+// their string representation.
+func parseRetryOn(values []interface{}) ([]string, error) {
+	codes := make([]string, 0, len(values))
+	for _, v := range values {
+		code := strings.ToLower(fmt.Sprint(v))
+		if !validRetryCode(code) {
+			return nil, fmt.Errorf("invalid status code %q", code)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// retryAfter parses the value of the `Retry-After` header of a response, which
+// is either a number of seconds or a HTTP date.
+func retryAfter(headers map[string]string, now time.Time) (time.Duration, bool) {
+	v, ok := headers["Retry-After"]
+	if !ok || v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for the given duration, or returns early with an error
+// if the context is done before.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
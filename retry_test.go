@@ -0,0 +1,109 @@
+package loki
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryConfigShouldRetry(t *testing.T) {
+	cfg := RetryConfig{MaxRetries: 3, RetryOn: DefaultRetryOn}
+	for status, expected := range map[int]bool{
+		0:   true,
+		204: false,
+		400: false,
+		429: true,
+		500: true,
+		503: true,
+	} {
+		if got := cfg.shouldRetry(status); got != expected {
+			t.Errorf("status %d: expected %v, got %v", status, expected, got)
+		}
+	}
+
+	if (RetryConfig{RetryOn: DefaultRetryOn}).shouldRetry(429) {
+		t.Error("expected no retry when retries are disabled")
+	}
+}
+
+func TestRetryConfigBackoff(t *testing.T) {
+	cfg := RetryConfig{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	r := rand.New(rand.NewSource(1))
+	for attempt, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		d := cfg.backoff(r, attempt)
+		if d < max/2 || d > max {
+			t.Errorf("attempt %d: expected backoff between %s and %s, got %s", attempt, max/2, max, d)
+		}
+	}
+	if d := cfg.backoff(r, 100); d > time.Second {
+		t.Errorf("expected backoff to be capped at %s, got %s", time.Second, d)
+	}
+}
+
+func TestParseRetryOn(t *testing.T) {
+	codes, err := parseRetryOn([]interface{}{int64(429), "5XX", "408"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 3 || codes[0] != "429" || codes[1] != "5xx" || codes[2] != "408" {
+		t.Fatalf("unexpected codes %v", codes)
+	}
+
+	for _, invalid := range []interface{}{"foo", int64(42), "9xx"} {
+		if _, err := parseRetryOn([]interface{}{invalid}); err == nil {
+			t.Errorf("expected error for %v", invalid)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		"missing": {"", 0, false},
+		"seconds": {"5", 5 * time.Second, true},
+		"date":    {now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		"past":    {now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		"invalid": {"soon", 0, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tc.value != "" {
+				headers["Retry-After"] = tc.value
+			}
+			d, ok := retryAfter(headers, now)
+			if ok != tc.ok || d != tc.expected {
+				t.Fatalf("expected (%s, %v), got (%s, %v)", tc.expected, tc.ok, d, ok)
+			}
+		})
+	}
+}
+
+func TestRetryConfigDelay(t *testing.T) {
+	cfg := RetryConfig{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Minute}
+	r := rand.New(rand.NewSource(1))
+	now := time.Now()
+	for header, expected := range map[string]time.Duration{
+		"10":    10 * time.Second,
+		"86400": time.Minute,
+		now.Add(time.Hour).UTC().Format(http.TimeFormat): time.Minute,
+	} {
+		if d := cfg.delay(r, 0, map[string]string{"Retry-After": header}, now); d != expected {
+			t.Errorf("Retry-After %s: expected delay %s, got %s", header, expected, d)
+		}
+	}
+	if d := cfg.delay(r, 0, nil, now); d < 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("expected backoff without Retry-After, got %s", d)
+	}
+}
@@ -0,0 +1,60 @@
+package loki
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+func TestStackTraceFormats(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	for format, prefix := range map[string]string{
+		"stacktrace_java":   "\tat com.",
+		"stacktrace_python": "  File \"/app/",
+		"stacktrace_go":     "goroutine ",
+	} {
+		line := c.logEntry(nil, format, time.Now()).Line
+		lines := strings.Split(line, "\n")
+		if len(lines) < 4 {
+			t.Fatalf("%s: expected multiline entry, got %q", format, line)
+		}
+		found := false
+		for _, l := range lines {
+			found = found || strings.HasPrefix(l, prefix)
+		}
+		if !found {
+			t.Errorf("%s: expected a line with prefix %q, got %q", format, prefix, line)
+		}
+	}
+}
+
+func TestStackTraceRatio(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker), stacktraceRatio: 1}
+
+	line := c.logEntry(nil, "json", time.Now()).Line
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		t.Fatalf("expected valid JSON, got %q: %v", line, err)
+	}
+	if _, ok := fields["stacktrace"]; !ok {
+		t.Errorf("expected stacktrace field, got %q", line)
+	}
+
+	for _, format := range []string{"logfmt", "logfmt_wide"} {
+		line := c.logEntry(nil, format, time.Now()).Line
+		if strings.Contains(line, "\n") || !strings.Contains(line, ` stacktrace="`) {
+			t.Errorf("expected %s line with quoted stacktrace field, got %q", format, line)
+		}
+	}
+
+	if line := c.logEntry(nil, "apache_common", time.Now()).Line; !strings.Contains(line, "\n") {
+		t.Errorf("expected multiline entry, got %q", line)
+	}
+}
@@ -0,0 +1,163 @@
+package loki
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/metrics"
+)
+
+// ClientStats holds the write statistics of a client that are exposed to the
+// Javascript runtime via `client.stats()`.
+type ClientStats struct {
+	// Streams is the number of unique streams written by the client
+	Streams int `js:"streams"`
+	// ActiveStreams is the number of unique streams written by the client,
+	// that were not rotated out by label churn since
+	ActiveStreams int `js:"activeStreams"`
+	// Lines is the number of lines written by the client
+	Lines int64 `js:"lines"`
+	// Bytes is the quantity of uncompressed log data written by the client
+	Bytes int64 `js:"bytes"`
+	// LabelBytes is the quantity of uncompressed log data written per label
+	// name and label value
+	LabelBytes map[string]map[string]int64 `js:"labelBytes"`
+}
+
+// writeStats keeps track of the streams and bytes per label value that
+// were successfully pushed by a client.
+type writeStats struct {
+	streams    map[uint64]struct{}
+	active     map[uint64]model.LabelSet
+	lines      int64
+	bytes      int64
+	labelBytes map[string]map[string]int64
+}
+
+func newWriteStats() *writeStats {
+	return &writeStats{
+		streams:    make(map[uint64]struct{}),
+		active:     make(map[uint64]model.LabelSet),
+		labelBytes: make(map[string]map[string]int64),
+	}
+}
+
+// hashLabels returns the hash of a label string
+func hashLabels(labels string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(labels))
+	return h.Sum64()
+}
+
+// add accounts the streams of a successfully pushed batch. It returns the
+// bytes per label name and value of the batch, and the number of streams that
+// were not written before.
+func (s *writeStats) add(batch *Batch) (map[string]map[string]int64, int) {
+	batchLabelBytes := make(map[string]map[string]int64)
+	newStreams := 0
+	for key, stream := range batch.Streams {
+		h := hashLabels(stream.Labels)
+		if _, ok := s.streams[h]; !ok {
+			s.streams[h] = struct{}{}
+			newStreams++
+		}
+		s.active[h] = batch.labels[key]
+
+		bytes := int64(0)
+		for _, entry := range stream.Entries {
+			bytes += int64(len(entry.Line))
+		}
+		s.lines += int64(len(stream.Entries))
+		s.bytes += bytes
+
+		for name, value := range batch.labels[key] {
+			if _, ok := s.labelBytes[string(name)]; !ok {
+				s.labelBytes[string(name)] = make(map[string]int64)
+			}
+			s.labelBytes[string(name)][string(value)] += bytes
+			if _, ok := batchLabelBytes[string(name)]; !ok {
+				batchLabelBytes[string(name)] = make(map[string]int64)
+			}
+			batchLabelBytes[string(name)][string(value)] += bytes
+		}
+	}
+	return batchLabelBytes, newStreams
+}
+
+// expire removes the active streams whose labels are not `current` anymore,
+// because their values were rotated out by label churn.
+func (s *writeStats) expire(current func(model.LabelSet) bool) {
+	for h, labels := range s.active {
+		if !current(labels) {
+			delete(s.active, h)
+		}
+	}
+}
+
+// Stats returns the write statistics of the client.
+func (c *Client) Stats() ClientStats {
+	labelBytes := make(map[string]map[string]int64, len(c.stats.labelBytes))
+	for name, values := range c.stats.labelBytes {
+		labelBytes[name] = make(map[string]int64, len(values))
+		for value, bytes := range values {
+			labelBytes[name][value] = bytes
+		}
+	}
+	return ClientStats{
+		Streams:       len(c.stats.streams),
+		ActiveStreams: len(c.stats.active),
+		Lines:         c.stats.lines,
+		Bytes:         c.stats.bytes,
+		LabelBytes:    labelBytes,
+	}
+}
+
+// reportStatsFromBatch accounts the streams of a successfully pushed batch and
+// reports the number of active and new streams and the bytes per label name.
+func (c *Client) reportStatsFromBatch(batch *Batch) {
+	labelBytes, newStreams := c.stats.add(batch)
+
+	now := time.Now()
+	ctx := c.vu.Context()
+	ctm := c.vu.State().Tags.GetCurrentValues()
+
+	samples := []metrics.Sample{
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientActiveStreams,
+				Tags:   ctm.Tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(len(c.stats.active)),
+			Time:     now,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientNewStreams,
+				Tags:   ctm.Tags,
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(newStreams),
+			Time:     now,
+		},
+	}
+	// the metric is only tagged by label name, since tagging it by value
+	// would create a time series per label value
+	for name, values := range labelBytes {
+		bytes := int64(0)
+		for _, b := range values {
+			bytes += b
+		}
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.ClientLabelBytes,
+				Tags:   ctm.Tags.With("label", name),
+			},
+			Metadata: ctm.Metadata,
+			Value:    float64(bytes),
+			Time:     now,
+		})
+	}
+	metrics.PushIfNotDone(ctx, c.vu.State().Samples, metrics.ConnectedSamples{Samples: samples})
+}
@@ -0,0 +1,88 @@
+package loki
+
+import (
+	"testing"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/prometheus/common/model"
+)
+
+func TestWriteStats(t *testing.T) {
+	type stream struct {
+		labels  model.LabelSet
+		entries []push.Entry
+	}
+	batch := func(streams ...stream) *Batch {
+		b := &Batch{Streams: make(map[string]*push.Stream)}
+		for _, s := range streams {
+			b.stream(s.labels).Entries = s.entries
+		}
+		return b
+	}
+	api := model.LabelSet{"app": "api", "namespace": "prod"}
+	s := newWriteStats()
+
+	_, newStreams := s.add(batch(
+		stream{api, []push.Entry{{Line: "foo"}, {Line: "bar"}}},
+		stream{model.LabelSet{"app": "batch", "namespace": "prod"}, []push.Entry{{Line: "hello world"}}},
+	))
+	if newStreams != 2 {
+		t.Errorf("expected 2 new streams in first batch, got %d", newStreams)
+	}
+	labelBytes, newStreams := s.add(batch(
+		stream{api, []push.Entry{{Line: "buzz"}}},
+	))
+
+	if newStreams != 0 {
+		t.Errorf("expected no new streams in second batch, got %d", newStreams)
+	}
+	if len(s.streams) != 2 {
+		t.Errorf("expected 2 unique streams, got %d", len(s.streams))
+	}
+	if s.lines != 4 || s.bytes != 21 {
+		t.Errorf("expected 4 lines and 21 bytes, got %d lines and %d bytes", s.lines, s.bytes)
+	}
+	if b := s.labelBytes["app"]["api"]; b != 10 {
+		t.Errorf("expected 10 bytes for app=api, got %d", b)
+	}
+	if b := s.labelBytes["namespace"]["prod"]; b != 21 {
+		t.Errorf("expected 21 bytes for namespace=prod, got %d", b)
+	}
+	if b := labelBytes["app"]["api"]; b != 4 || len(labelBytes["app"]) != 1 {
+		t.Errorf("expected only 4 bytes for app=api in last batch, got %v", labelBytes["app"])
+	}
+}
+
+func TestWriteStatsSpecialLabelValues(t *testing.T) {
+	s := newWriteStats()
+	b := &Batch{Streams: make(map[string]*push.Stream)}
+	b.stream(model.LabelSet{"company": `KidAdmit, Inc.`, "quote": `say "hi", bye`}).Entries = []push.Entry{{Line: "foo"}}
+
+	s.add(b)
+	if v := s.labelBytes["company"]["KidAdmit, Inc."]; v != 3 {
+		t.Errorf("expected 3 bytes for company=KidAdmit, Inc., got %v", s.labelBytes["company"])
+	}
+	if v := s.labelBytes["quote"][`say "hi", bye`]; v != 3 {
+		t.Errorf(`expected 3 bytes for quote=say "hi", bye, got %v`, s.labelBytes["quote"])
+	}
+}
+
+func TestWriteStatsExpire(t *testing.T) {
+	s := newWriteStats()
+	b := &Batch{Streams: make(map[string]*push.Stream)}
+	for _, pod := range []model.LabelValue{"pod-1", "pod-2", "pod-3"} {
+		b.stream(model.LabelSet{"app": "api", "pod": pod}).Entries = []push.Entry{{Line: "foo"}}
+	}
+	b.stream(model.LabelSet{"app": "canary"}).Entries = []push.Entry{{Line: "foo"}}
+	s.add(b)
+
+	// pod-2 was rotated out, streams without pod label stay active
+	pods := labelValues{name: "pod", values: []string{"pod-1", "pod-3", "pod-4"}}
+	s.expire(pods.hasValue())
+	if len(s.active) != 3 {
+		t.Errorf("expected 3 active streams, got %d", len(s.active))
+	}
+	if len(s.streams) != 4 {
+		t.Errorf("expected 4 unique streams, got %d", len(s.streams))
+	}
+}
@@ -0,0 +1,141 @@
+package loki
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/sobek"
+	"github.com/prometheus/common/model"
+	"go.k6.io/k6/lib/netext/httpext"
+)
+
+// PushStream is a stream with fixed labels that is pushed with PushStreams.
+// Its log lines are either given verbatim, or Count lines are generated in
+// the given Format.
+type PushStream struct {
+	Labels model.LabelSet
+	Lines  []string
+	Count  int
+	// Format is the format of the generated lines. It defaults to the
+	// `format` label of the stream.
+	Format string
+}
+
+// PushStreams pushes a batch with the given streams, e.g. to push a canary
+// stream with known labels and lines
+func (c *Client) PushStreams(v sobek.Value) (httpext.Response, error) {
+	if state := c.vu.State(); state == nil {
+		return *httpext.NewResponse(), errors.New("state is nil")
+	}
+	streams, err := c.parsePushStreams(v)
+	if err != nil {
+		return *httpext.NewResponse(), err
+	}
+	batch, err := c.newStreamsBatch(streams)
+	if err != nil {
+		return *httpext.NewResponse(), err
+	}
+	return c.pushBatch(batch)
+}
+
+func (c *Client) parsePushStreams(v sobek.Value) ([]PushStream, error) {
+	rt := c.vu.Runtime()
+	var values []sobek.Value
+	if isNully(v) {
+		return nil, errors.New("streams should be a list of objects")
+	}
+	if err := rt.ExportTo(v, &values); err != nil {
+		return nil, fmt.Errorf("streams should be a list of objects: %w", err)
+	}
+	streams := make([]PushStream, 0, len(values))
+	for i, value := range values {
+		if isNully(value) {
+			return nil, fmt.Errorf("stream %d should be an object", i)
+		}
+		o := value.ToObject(rt)
+		s := PushStream{}
+		if v := o.Get("labels"); !isNully(v) {
+			var labels map[string]string
+			if err := rt.ExportTo(v, &labels); err != nil {
+				return nil, fmt.Errorf("labels of stream %d should be a map of string to string: %w", i, err)
+			}
+			s.Labels = make(model.LabelSet, len(labels))
+			for k, v := range labels {
+				s.Labels[model.LabelName(k)] = model.LabelValue(v)
+			}
+		}
+		if v := o.Get("lines"); !isNully(v) {
+			if _, ok := v.Export().([]interface{}); ok {
+				if err := rt.ExportTo(v, &s.Lines); err != nil {
+					return nil, fmt.Errorf("lines of stream %d should be a list of strings: %w", i, err)
+				}
+			} else {
+				lines := v.ToObject(rt)
+				if v := lines.Get("count"); !isNully(v) {
+					s.Count = int(v.ToInteger())
+				}
+				if v := lines.Get("format"); !isNully(v) {
+					s.Format = v.String()
+				}
+			}
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+// newStreamsBatch creates a batch with the given streams. Generated lines
+// have the same content as lines of streams of pushParameterized, while
+// verbatim lines are pushed as they are.
+func (c *Client) newStreamsBatch(streams []PushStream) (*Batch, error) {
+	if len(streams) == 0 {
+		return nil, errors.New("a batch needs at least one stream")
+	}
+	batch := &Batch{
+		Streams:   make(map[string]*push.Stream, len(streams)),
+		CreatedAt: time.Now(),
+	}
+	for i, s := range streams {
+		if len(s.Labels) == 0 {
+			return nil, fmt.Errorf("stream %d needs at least one label", i)
+		}
+		if err := s.Labels.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid labels of stream %d: %w", i, err)
+		}
+		if s.Count < 0 {
+			return nil, fmt.Errorf("count of stream %d needs to be positive, got %d", i, s.Count)
+		}
+		if len(s.Lines) == 0 && s.Count == 0 {
+			return nil, fmt.Errorf("stream %d needs either lines or a count", i)
+		}
+
+		var logFmt string
+		if s.Count > 0 {
+			logFmt = s.Format
+			if logFmt == "" {
+				logFmt = string(s.Labels[model.LabelName("format")])
+			}
+			if !isValidLogFormat(logFmt) {
+				return nil, fmt.Errorf("%q is not a valid log format of stream %d", logFmt, i)
+			}
+		}
+
+		// streams with the same labels are merged
+		stream := batch.stream(s.Labels)
+		for _, line := range s.Lines {
+			stream.Entries = append(stream.Entries, push.Entry{Timestamp: time.Now(), Line: line})
+			batch.Bytes += len(line)
+		}
+		for j := 0; j < s.Count; j++ {
+			entry := c.logEntry(s.Labels, logFmt, time.Now())
+			stream.Entries = append(stream.Entries, entry)
+			batch.Bytes += len(entry.Line)
+		}
+	}
+	c.findNeedles(batch)
+	c.collectFuzzedLines(batch)
+
+	return batch, nil
+}
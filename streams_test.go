@@ -0,0 +1,96 @@
+package loki
+
+import (
+	"testing"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/xk6-loki/flog"
+	json "github.com/mailru/easyjson"
+	"github.com/prometheus/common/model"
+)
+
+func TestNewStreamsBatch(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	canary := model.LabelSet{"app": "canary"}
+	generated := model.LabelSet{"app": "api", "format": "logfmt"}
+	batch, err := c.newStreamsBatch([]PushStream{
+		{Labels: canary, Lines: []string{"canary 1", "canary 2"}},
+		{Labels: generated, Count: 10},
+		{Labels: canary, Count: 5, Format: "json"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Streams) != 2 {
+		t.Fatalf("expected 2 streams, got %d", len(batch.Streams))
+	}
+	stream := batch.Streams[canary.String()]
+	if len(stream.Entries) != 7 {
+		t.Fatalf("expected 7 canary lines, got %d", len(stream.Entries))
+	}
+	if stream.Entries[0].Line != "canary 1" || stream.Entries[1].Line != "canary 2" {
+		t.Fatalf("expected verbatim lines, got %q and %q", stream.Entries[0].Line, stream.Entries[1].Line)
+	}
+	if n := len(batch.Streams[generated.String()].Entries); n != 10 {
+		t.Fatalf("expected 10 generated lines, got %d", n)
+	}
+	bytes := 0
+	for _, s := range batch.Streams {
+		for _, e := range s.Entries {
+			bytes += len(e.Line)
+		}
+	}
+	if batch.Bytes != bytes {
+		t.Fatalf("expected %d bytes, got %d", bytes, batch.Bytes)
+	}
+}
+
+func TestNewStreamsBatchErrors(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	for name, streams := range map[string][]PushStream{
+		"no streams":     nil,
+		"no labels":      {{Lines: []string{"line"}}},
+		"invalid labels": {{Labels: model.LabelSet{"app": "\xff"}, Lines: []string{"line"}}},
+		"no lines":       {{Labels: model.LabelSet{"app": "api"}}},
+		"no format":      {{Labels: model.LabelSet{"app": "api"}, Count: 1}},
+		"invalid format": {{Labels: model.LabelSet{"app": "api"}, Count: 1, Format: "xml"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := c.newStreamsBatch(streams); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestNewStreamsBatchSpecialLabelValues(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	labels := model.LabelSet{"team": "a, b", "owner": `say "hi"`}
+	batch, err := c.newStreamsBatch([]PushStream{{Labels: labels, Lines: []string{"line"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _, err := batch.encodeJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req JSONPushRequest
+	if err := json.Unmarshal(buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	if s := req.Streams[0].Stream; s["team"] != "a, b" || s["owner"] != `say "hi"` {
+		t.Fatalf("expected labels %v, got %v", labels, s)
+	}
+
+	stats := newWriteStats()
+	stats.add(batch)
+	if b := stats.labelBytes["team"]["a, b"]; b != 4 {
+		t.Fatalf("expected 4 bytes for team=a, b, got %v", stats.labelBytes["team"])
+	}
+}
@@ -0,0 +1,95 @@
+package loki
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+)
+
+const (
+	// TraceModeField adds the trace and span IDs as fields to the log line
+	TraceModeField = "field"
+	// TraceModeMetadata adds the trace and span IDs as structured metadata
+	// to the log entry
+	TraceModeMetadata = "metadata"
+)
+
+const DefaultTracePoolSize = 1000
+
+// Traces defines how trace and span IDs are added to log entries. The trace
+// IDs are drawn from a pool that is generated from Seed, so all clients with
+// the same seed share the same pool.
+type Traces struct {
+	// Ratio is the ratio of log entries that have a trace ID
+	Ratio    float64
+	PoolSize int
+	Seed     int64
+	// Mode is either field or metadata
+	Mode string
+}
+
+type tracePool struct {
+	Traces
+	ids []string
+}
+
+func newTracePool(t Traces) (*tracePool, error) {
+	if t.Ratio < 0 || t.Ratio > 1 {
+		return nil, fmt.Errorf("ratio needs to be between 0 and 1, got %v", t.Ratio)
+	}
+	if t.PoolSize <= 0 {
+		return nil, fmt.Errorf("poolSize needs to be greater than 0, got %d", t.PoolSize)
+	}
+	if t.Mode != TraceModeField && t.Mode != TraceModeMetadata {
+		return nil, fmt.Errorf("unknown mode %q, must be %s or %s", t.Mode, TraceModeField, TraceModeMetadata)
+	}
+
+	r := rand.New(rand.NewSource(t.Seed))
+	ids := make([]string, t.PoolSize)
+	for i := range ids {
+		ids[i] = randomHex(r, 16)
+	}
+	return &tracePool{Traces: t, ids: ids}, nil
+}
+
+// next returns a random trace ID from the pool and a new span ID
+func (p *tracePool) next(r *rand.Rand) (string, string) {
+	return p.ids[r.Intn(len(p.ids))], randomHex(r, 8)
+}
+
+// add adds a trace and span ID to the given ratio of entries
+func (p *tracePool) add(r *rand.Rand, entry *push.Entry) {
+	if r.Float64() >= p.Ratio {
+		return
+	}
+	traceID, spanID := p.next(r)
+	if p.Mode == TraceModeMetadata {
+		entry.StructuredMetadata = append(entry.StructuredMetadata,
+			push.LabelAdapter{Name: "trace_id", Value: traceID},
+			push.LabelAdapter{Name: "span_id", Value: spanID},
+		)
+		return
+	}
+	entry.Line = flog.AppendField(flog.AppendField(entry.Line, "trace_id", traceID), "span_id", spanID)
+}
+
+// randomHex returns n random bytes in hexadecimal encoding
+func randomHex(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	_, _ = r.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TraceIDs returns the pool of trace IDs that are added to log entries, or
+// nil if trace IDs are not enabled.
+func (c *Client) TraceIDs() []string {
+	if c.traces == nil {
+		return nil
+	}
+	ids := make([]string, len(c.traces.ids))
+	copy(ids, c.traces.ids)
+	return ids
+}
@@ -0,0 +1,99 @@
+package loki
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gofakeit "github.com/brianvoe/gofakeit/v6"
+	"github.com/grafana/loki/pkg/push"
+	"github.com/grafana/xk6-loki/flog"
+	json "github.com/mailru/easyjson"
+	"github.com/prometheus/common/model"
+)
+
+func TestTracePoolIsShared(t *testing.T) {
+	a, err := newTracePool(Traces{Ratio: 1, PoolSize: 10, Seed: 42, Mode: TraceModeField})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newTracePool(Traces{Ratio: 1, PoolSize: 10, Seed: 42, Mode: TraceModeField})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(a.ids, b.ids) {
+		t.Fatalf("expected pools with the same seed to be equal, got %v and %v", a.ids, b.ids)
+	}
+	if len(a.ids[0]) != 32 {
+		t.Errorf("expected trace ID with 32 characters, got %q", a.ids[0])
+	}
+}
+
+func TestTraceModes(t *testing.T) {
+	faker := gofakeit.New(12345)
+	c := Client{rand: faker.Rand, faker: faker, flog: flog.New(faker.Rand, faker)}
+
+	for _, mode := range []string{TraceModeField, TraceModeMetadata} {
+		traces, err := newTracePool(Traces{Ratio: 1, PoolSize: 5, Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.traces = traces
+
+		entry := c.logEntry(nil, "logfmt", time.Now())
+		var traceID string
+		if mode == TraceModeField {
+			_, after, ok := strings.Cut(entry.Line, " trace_id=")
+			if !ok {
+				t.Fatalf("expected trace_id field, got %q", entry.Line)
+			}
+			traceID, _, _ = strings.Cut(after, " ")
+		} else {
+			if len(entry.StructuredMetadata) != 2 || entry.StructuredMetadata[0].Name != "trace_id" {
+				t.Fatalf("expected trace_id metadata, got %v", entry.StructuredMetadata)
+			}
+			traceID = entry.StructuredMetadata[0].Value
+		}
+		if !slices.Contains(c.TraceIDs(), traceID) {
+			t.Errorf("%s: expected trace ID %q to be in pool %v", mode, traceID, c.TraceIDs())
+		}
+	}
+}
+
+func TestJSONEntryWithStructuredMetadata(t *testing.T) {
+	ts := time.Unix(0, 1000)
+	batch := &Batch{Streams: map[string]*push.Stream{}}
+	batch.stream(model.LabelSet{"app": "foo"}).Entries = []push.Entry{
+		{Timestamp: ts, Line: "without metadata"},
+		{Timestamp: ts, Line: "with metadata", StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}}},
+	}
+	buf, _, err := batch.encodeJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"streams":[{"stream":{"app":"foo"},"values":[["1000","without metadata"],["1000","with metadata",{"trace_id":"abc"}]]}]}`
+	if string(buf) != expected {
+		t.Fatalf("expected %s, got %s", expected, buf)
+	}
+
+	var req JSONPushRequest
+	if err := json.Unmarshal(buf, &req); err != nil {
+		t.Fatal(err)
+	}
+	if v := req.Streams[0].Values[1].StructuredMetadata["trace_id"]; v != "abc" {
+		t.Errorf("expected trace_id abc, got %q", v)
+	}
+}
+
+func TestNewTracePoolInvalid(t *testing.T) {
+	for _, traces := range []Traces{
+		{Ratio: 2, PoolSize: 1, Mode: TraceModeField},
+		{Ratio: 1, PoolSize: 0, Mode: TraceModeField},
+		{Ratio: 1, PoolSize: 1, Mode: "label"},
+	} {
+		if _, err := newTracePool(traces); err == nil {
+			t.Errorf("expected error for %+v", traces)
+		}
+	}
+}